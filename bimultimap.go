@@ -0,0 +1,134 @@
+package lodago
+
+import "iter"
+
+// 双向的multimap，同时维护key到values和value到keys两个索引，每次插入和移除都同时更新两边，
+// 两个方向都是SetMultimap，同一个键值对只保存一次。Inverse返回交换了两个索引的视图，和原来的共享数据。
+
+// BiMultimap 双向的multimap
+type BiMultimap[K comparable, V comparable] struct {
+	forward *SetMultimap[K, V]
+	inverse *SetMultimap[V, K]
+}
+
+var _ Multimapper[string, int] = (*BiMultimap[string, int])(nil)
+
+// NewBiMultimap 构建双向的multimap
+func NewBiMultimap[K comparable, V comparable]() *BiMultimap[K, V] {
+	return &BiMultimap[K, V]{
+		forward: NewSetMultimap[K, V](),
+		inverse: NewSetMultimap[V, K](),
+	}
+}
+
+// Inverse 返回value到keys的视图，和原来的multimap共享数据，任意一边的修改另一边都能看到
+func (multimap *BiMultimap[K, V]) Inverse() *BiMultimap[V, K] {
+	return &BiMultimap[V, K]{forward: multimap.inverse, inverse: multimap.forward}
+}
+
+// At 取出key对应的values
+func (multimap *BiMultimap[K, V]) At(key K) ([]V, bool) {
+	return multimap.forward.At(key)
+}
+
+// Contains 判断是否有key和value的键值对
+func (multimap *BiMultimap[K, V]) Contains(key K, value V) bool {
+	return multimap.forward.Contains(key, value)
+}
+
+// Insert 插入一条记录，键值对已经存在时不插入
+func (multimap *BiMultimap[K, V]) Insert(key K, value V) {
+	multimap.forward.Insert(key, value)
+	multimap.inverse.Insert(value, key)
+}
+
+// InsertValues 插入多条记录
+func (multimap *BiMultimap[K, V]) InsertValues(key K, values []V) {
+	for _, v := range values {
+		multimap.Insert(key, v)
+	}
+}
+
+// Remove 移除一条记录
+func (multimap *BiMultimap[K, V]) Remove(key K, value V) {
+	multimap.forward.Remove(key, value)
+	multimap.inverse.Remove(value, key)
+}
+
+// RemoveAll 删除关于key的所有键值对
+func (multimap *BiMultimap[K, V]) RemoveAll(key K) {
+	values, _ := multimap.forward.At(key)
+	for _, v := range values {
+		multimap.inverse.Remove(v, key)
+	}
+	multimap.forward.RemoveAll(key)
+}
+
+// RemoveValue 删除关于value的所有键值对，等同于Inverse().RemoveAll(value)
+func (multimap *BiMultimap[K, V]) RemoveValue(value V) {
+	multimap.Inverse().RemoveAll(value)
+}
+
+// Size 获取map当前键值对的数量
+func (multimap *BiMultimap[K, V]) Size() int {
+	return multimap.forward.Size()
+}
+
+// IsEmpty 判断容器内是否为空
+func (multimap *BiMultimap[K, V]) IsEmpty() bool {
+	return multimap.forward.IsEmpty()
+}
+
+// Count 获取key对应的value数量
+func (multimap *BiMultimap[K, V]) Count(key K) int {
+	return multimap.forward.Count(key)
+}
+
+// Keys 获取所有的key，顺序不固定
+func (multimap *BiMultimap[K, V]) Keys() []K {
+	return multimap.forward.Keys()
+}
+
+// Values 获取所有的value，同SetMultimap.Values
+func (multimap *BiMultimap[K, V]) Values() []V {
+	return multimap.forward.Values()
+}
+
+// Entries 获取所有的键值对
+func (multimap *BiMultimap[K, V]) Entries() []MultimapEntry[K, V] {
+	return multimap.forward.Entries()
+}
+
+// ForEach 遍历所有的键值对，fn返回false时停止，fn中不能修改这个multimap
+func (multimap *BiMultimap[K, V]) ForEach(fn func(key K, value V) bool) {
+	multimap.forward.ForEach(fn)
+}
+
+// All 返回遍历所有键值对的迭代器，循环中不能修改这个multimap
+func (multimap *BiMultimap[K, V]) All() iter.Seq2[K, V] {
+	return multimap.forward.All()
+}
+
+// ToMap 转换成key到values的map[K][]V，返回的是副本
+func (multimap *BiMultimap[K, V]) ToMap() map[K][]V {
+	return multimap.forward.ToMap()
+}
+
+// MarshalJSON 序列化key到values的索引{"key": [values...]}，实现json.Marshaler
+func (multimap *BiMultimap[K, V]) MarshalJSON() ([]byte, error) {
+	return multimap.forward.MarshalJSON()
+}
+
+// UnmarshalJSON 从{"key": [values...]}反序列化，替换原有的内容并重建反向索引，实现json.Unmarshaler。
+// 原地清空两个索引，已经通过Inverse得到的视图也能看到反序列化之后的内容
+func (multimap *BiMultimap[K, V]) UnmarshalJSON(data []byte) error {
+	return unmarshalMultimap(data, func() {
+		if multimap.forward == nil {
+			multimap.forward = NewSetMultimap[K, V]()
+			multimap.inverse = NewSetMultimap[V, K]()
+			return
+		}
+		*multimap.forward = *NewSetMultimap[K, V]()
+		*multimap.inverse = *NewSetMultimap[V, K]()
+	}, multimap.InsertValues)
+}
@@ -0,0 +1,218 @@
+// lodacron 校验、解释和预览CronTime定义的命令行工具。
+//
+// 用法:
+//
+//	lodacron check [-n 5] [file]  校验CronTime的json（单个对象或数组），输出spec、描述和接下来n次执行时间
+//	lodacron run [file]           在前台按照时间表执行shell命令，用于本地测试
+//
+// file为空或者为"-"时从标准输入读取。run使用的json格式为:
+//
+//	[{"name": "backup", "command": "echo backup", "times": [{"type": 4, "hour": "2", "minute": "0"}]}]
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/93Alliance/lodago"
+	json "github.com/json-iterator/go"
+	"github.com/robfig/cron/v3"
+)
+
+// Task run命令中的一个任务
+type Task struct {
+	Name    string             `json:"name"`
+	Command string             `json:"command"`
+	Time    *lodago.CronTime   `json:"time"`  // 单个时间表
+	Times   []*lodago.CronTime `json:"times"` // 多个时间表
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "check":
+		err = check(os.Stdout, os.Args[2:])
+	case "run":
+		err = run(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage:
+  lodacron check [-n 5] [file]  validate CronTime json, print spec, description and next run times
+  lodacron run [file]           run shell commands on their schedules in the foreground
+
+file is a json object or array, "-" or empty reads from stdin.`)
+}
+
+// check 校验时间表，把每个时间表的说明写到w
+func check(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	n := fs.Int("n", 5, "number of next run times to print")
+	fs.Parse(args)
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var cronTimes []*lodago.CronTime
+	if err := decode(data, &cronTimes); err != nil {
+		return err
+	}
+	invalid := 0
+	for i, cronTime := range cronTimes {
+		if !explain(w, i+1, cronTime, *n) {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d schedules are invalid", invalid, len(cronTimes))
+	}
+	return nil
+}
+
+// explain 输出一个时间表的说明，返回是否合法
+func explain(w io.Writer, idx int, cronTime *lodago.CronTime, n int) bool {
+	schedule, err := validate(cronTime)
+	if err != nil {
+		fmt.Fprintf(w, "#%d error: %v\n", idx, err)
+		return false
+	}
+	spec, err := cronTime.ToSpec()
+	if err != nil {
+		spec = "-"
+	}
+	desc, _ := cronTime.Describe()
+	fmt.Fprintf(w, "#%d ok\n", idx)
+	fmt.Fprintf(w, "  spec: %s\n", spec)
+	fmt.Fprintf(w, "  desc: %s\n", desc)
+	next := time.Now()
+	for i := 0; i < n; i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		label := "       "
+		if i == 0 {
+			label = "  next:"
+		}
+		fmt.Fprintf(w, "%s %s\n", label, next.Format("2006-01-02 15:04:05 Mon MST"))
+	}
+	return true
+}
+
+// validate 转换成时间表，间隔执行的时间表间隔不能为0（例如@every 0h0m），否则cron会每秒执行一次
+func validate(cronTime *lodago.CronTime) (cron.Schedule, error) {
+	schedule, err := cronTime.ToSchedule()
+	if err != nil {
+		return nil, err
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok && every.Delay < time.Minute {
+		return nil, errors.New("Interval must be at least one minute")
+	}
+	return schedule, nil
+}
+
+// run 在前台执行任务，收到中断信号后退出
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Parse(args)
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var tasks []*Task
+	if err := decode(data, &tasks); err != nil {
+		return err
+	}
+	c := lodago.NewCrontab()
+	for i, task := range tasks {
+		if task.Name == "" {
+			task.Name = fmt.Sprintf("task-%d", i+1)
+		}
+		times := task.Times
+		if task.Time != nil {
+			times = append([]*lodago.CronTime{task.Time}, times...)
+		}
+		if task.Command == "" || len(times) == 0 {
+			return fmt.Errorf("Task %s has no command or time", task.Name)
+		}
+		for _, cronTime := range times {
+			if _, err := validate(cronTime); err != nil {
+				return fmt.Errorf("Task %s: %v", task.Name, err)
+			}
+		}
+		if _, err := c.AddJobs(times, command(task.Name, task.Command)); err != nil {
+			return fmt.Errorf("Task %s: %v", task.Name, err)
+		}
+		for j, cronTime := range times {
+			explain(os.Stdout, j+1, cronTime, 1)
+		}
+		log.Printf("[%s] scheduled: %s", task.Name, task.Command)
+	}
+	c.Start()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	log.Print("stopping")
+	c.Stop()
+	return nil
+}
+
+// command 执行shell命令的任务
+func command(name, cmdline string) lodago.Job {
+	return func() {
+		start := time.Now()
+		log.Printf("[%s] start", name)
+		cmd := exec.Command("sh", "-c", cmdline)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("[%s] failed after %v: %v", name, time.Since(start), err)
+			return
+		}
+		log.Printf("[%s] done in %v", name, time.Since(start))
+	}
+}
+
+// readInput 读取文件，文件名为空或者为"-"时读取标准输入
+func readInput(file string) ([]byte, error) {
+	if file == "" || file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+// decode 解析json，单个对象当作只有一个元素的数组
+func decode(data []byte, v interface{}) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return errors.New("Input is empty")
+	}
+	if data[0] != '[' {
+		data = append(append([]byte{'['}, data...), ']')
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSpec 把json写到临时文件，返回文件路径
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "spec.json")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string   // 为空时表示合法
+		output  []string // 输出中需要包含的内容
+	}{
+		{
+			name:    "single object",
+			content: `{"type": 4, "hour": "2", "minute": "30"}`,
+			output:  []string{"#1 ok", "spec: 30 2 * * *", "desc: every day at 02:30", "next:"},
+		},
+		{
+			name:    "array",
+			content: `[{"type": 4, "hour": "2", "minute": "0"}, {"type": 8, "hour": "1", "minute": "30"}]`,
+			output:  []string{"#1 ok", "#2 ok", "spec: @every 1h30m"},
+		},
+		{
+			name:    "zero interval",
+			content: `[{"type": 8, "hour": "0", "minute": "0"}, {"type": 8, "hour": "0", "minute": "1"}]`,
+			err:     "1 of 2 schedules are invalid",
+			output:  []string{"#1 error: Interval must be at least one minute", "#2 ok"},
+		},
+		{
+			name:    "invalid time",
+			content: `[{"type": 4, "hour": "25", "minute": "0"}, {"type": 4, "hour": "x", "minute": "0"}]`,
+			err:     "2 of 2 schedules are invalid",
+			output:  []string{"#1 error:", "#2 error:"},
+		},
+		{
+			name:    "malformed json",
+			content: `[{"type": 4,`,
+			err:     "lodago.CronTime",
+		},
+		{
+			name:    "empty file",
+			content: " \n",
+			err:     "Input is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := check(&out, []string{"-n", "2", writeSpec(t, tt.content)})
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("check = %v, output:\n%s", err, out.String())
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("check = %v, want %q", err, tt.err)
+			}
+			for _, want := range tt.output {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	if err := check(&bytes.Buffer{}, []string{filepath.Join(t.TempDir(), "missing.json")}); !os.IsNotExist(err) {
+		t.Fatalf("check missing file = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,149 @@
+package lodago
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// 线程安全的multimap，key按照哈希值分散到多个分片，每个分片有自己的读写锁，
+// 不同分片的操作互不影响。
+
+// 默认的分片数量
+const defaultMultimapShards = 32
+
+// multimap的分片
+type multimapShard[K comparable, V any] struct {
+	locker sync.RWMutex
+	m      *MultimapOf[K, V]
+}
+
+// ConcurrentMultimap 线程安全的multimap，key的类型为K，value的类型为V
+type ConcurrentMultimap[K comparable, V any] struct {
+	shards   []*multimapShard[K, V]
+	shardMod uint64
+	seed     maphash.Seed
+	size     int64
+}
+
+// NewConcurrentMultimap 构建线程安全的multimap，shards为可选的分片数量，向上取整为2的次方，默认32
+func NewConcurrentMultimap[K comparable, V any](shards ...int) *ConcurrentMultimap[K, V] {
+	num := uint64(defaultMultimapShards)
+	if len(shards) > 0 && shards[0] > 0 {
+		num = minQuantity(uint64(shards[0]))
+	}
+	multimap := &ConcurrentMultimap[K, V]{
+		shards:   make([]*multimapShard[K, V], num),
+		shardMod: num - 1,
+		seed:     maphash.MakeSeed(),
+	}
+	for i := range multimap.shards {
+		multimap.shards[i] = &multimapShard[K, V]{m: NewMultimapOf[K, V]()}
+	}
+	return multimap
+}
+
+// At 取出key对应的values的副本
+func (multimap *ConcurrentMultimap[K, V]) At(key K) ([]V, bool) {
+	shard := multimap.shard(key)
+	shard.locker.RLock()
+	defer shard.locker.RUnlock()
+	values, found := shard.m.At(key)
+	return append([]V(nil), values...), found
+}
+
+// Insert 插入一条记录
+func (multimap *ConcurrentMultimap[K, V]) Insert(key K, value V) {
+	shard := multimap.shard(key)
+	shard.locker.Lock()
+	shard.m.Insert(key, value)
+	shard.locker.Unlock()
+	atomic.AddInt64(&multimap.size, 1)
+}
+
+// InsertValues 插入多条记录
+func (multimap *ConcurrentMultimap[K, V]) InsertValues(key K, values []V) {
+	multimap.update(key, func(m *MultimapOf[K, V]) {
+		m.InsertValues(key, values)
+	})
+}
+
+// Remove 移除key下所有等于value的记录
+func (multimap *ConcurrentMultimap[K, V]) Remove(key K, value V) {
+	multimap.update(key, func(m *MultimapOf[K, V]) {
+		m.Remove(key, value)
+	})
+}
+
+// RemoveAll 删除关于key的所有键值对
+func (multimap *ConcurrentMultimap[K, V]) RemoveAll(key K) {
+	multimap.update(key, func(m *MultimapOf[K, V]) {
+		m.RemoveAll(key)
+	})
+}
+
+// Compute 在分片的写锁内原子地更新key对应的values，fn的参数为当前values的副本，
+// 返回新的values，返回空时删除key。返回更新之后的数量。fn中不能再访问这个multimap。
+func (multimap *ConcurrentMultimap[K, V]) Compute(key K, fn func(values []V, found bool) []V) int {
+	count := 0
+	multimap.update(key, func(m *MultimapOf[K, V]) {
+		values, found := m.At(key)
+		values = fn(append([]V(nil), values...), found)
+		m.RemoveAll(key)
+		m.InsertValues(key, values)
+		count = len(values)
+	})
+	return count
+}
+
+// Size 获取map当前键值对的数量
+func (multimap *ConcurrentMultimap[K, V]) Size() int {
+	return int(atomic.LoadInt64(&multimap.size))
+}
+
+// IsEmpty 判断容器内是否为空
+func (multimap *ConcurrentMultimap[K, V]) IsEmpty() bool {
+	return multimap.Size() == 0
+}
+
+// Count 获取key对应的value数量
+func (multimap *ConcurrentMultimap[K, V]) Count(key K) int {
+	shard := multimap.shard(key)
+	shard.locker.RLock()
+	defer shard.locker.RUnlock()
+	return shard.m.Count(key)
+}
+
+// Range 遍历所有的key和values，fn返回false时停止。每个分片在读锁内复制之后再调用fn，
+// 所以fn中可以修改这个multimap，遍历期间其他协程的修改不一定能看到。
+func (multimap *ConcurrentMultimap[K, V]) Range(fn func(key K, values []V) bool) {
+	for _, shard := range multimap.shards {
+		shard.locker.RLock()
+		entries := make(map[K][]V, len(shard.m.m))
+		for key, values := range shard.m.m {
+			entries[key] = append([]V(nil), values...)
+		}
+		shard.locker.RUnlock()
+		for key, values := range entries {
+			if !fn(key, values) {
+				return
+			}
+		}
+	}
+}
+
+// update 在分片的写锁内修改，并更新总数量
+func (multimap *ConcurrentMultimap[K, V]) update(key K, fn func(m *MultimapOf[K, V])) {
+	shard := multimap.shard(key)
+	shard.locker.Lock()
+	before := shard.m.Size()
+	fn(shard.m)
+	delta := shard.m.Size() - before
+	shard.locker.Unlock()
+	atomic.AddInt64(&multimap.size, int64(delta))
+}
+
+// shard 根据key的哈希值选择分片，maphash按照key的类型和值计算哈希，不需要先转换成字符串
+func (multimap *ConcurrentMultimap[K, V]) shard(key K) *multimapShard[K, V] {
+	return multimap.shards[maphash.Comparable(multimap.seed, key)&multimap.shardMod]
+}
@@ -0,0 +1,88 @@
+package lodago
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// 多个协程并发插入、移除和读取，用-race检查没有数据竞争，最后的结果和数量一致
+func TestConcurrentMultimapConcurrent(t *testing.T) {
+	m := NewConcurrentMultimap[int, int](8)
+	const workers, keys = 8, 64
+	rounds := stressCount() / 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				key := (w*rounds + i) % keys
+				m.Insert(key, w)
+				m.Insert(key, -1)
+				if values, _ := m.At(key); len(values) == 0 {
+					t.Errorf("key %d has no values right after Insert", key)
+					return
+				}
+				m.Remove(key, -1) // 移除所有的-1，其他协程插入的-1也会被移除
+				m.Count(key)
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			m.Range(func(int, []int) bool { return true })
+			m.Size()
+		}
+	}()
+	wg.Wait()
+
+	total := 0
+	m.Range(func(key int, values []int) bool {
+		for _, v := range values {
+			if v < 0 {
+				t.Errorf("key %d still has -1", key)
+			}
+		}
+		total += len(values)
+		return true
+	})
+	if total != workers*rounds || m.Size() != total {
+		t.Fatalf("Range saw %d values, Size = %d, want %d", total, m.Size(), workers*rounds)
+	}
+}
+
+func TestConcurrentMultimapKeys(t *testing.T) {
+	// interface{}类型的key按照类型和值区分，1和"1"是不同的key
+	m := NewConcurrentMultimap[interface{}, string]()
+	m.Insert(1, "int")
+	m.Insert("1", "string")
+	if values, _ := m.At(1); !reflect.DeepEqual(values, []string{"int"}) {
+		t.Fatalf("At(1) = %v", values)
+	}
+	if values, _ := m.At("1"); !reflect.DeepEqual(values, []string{"string"}) {
+		t.Fatalf(`At("1") = %v`, values)
+	}
+
+	s := NewConcurrentMultimap[string, int]()
+	s.InsertValues("a", []int{3, 1})
+	got := s.Compute("a", func(values []int, found bool) []int {
+		sort.Ints(values)
+		return append(values, 5)
+	})
+	if values, _ := s.At("a"); got != 3 || !reflect.DeepEqual(values, []int{1, 3, 5}) || s.Size() != 3 {
+		t.Fatalf("Compute = %d, values %v, size %d", got, values, s.Size())
+	}
+	if s.Compute("a", func([]int, bool) []int { return nil }) != 0 || !s.IsEmpty() {
+		t.Fatal("Compute returning nil did not delete the key")
+	}
+
+	// 选择分片不需要分配内存
+	key := "user-1001"
+	if allocs := testing.AllocsPerRun(100, func() { s.shard(key) }); allocs != 0 {
+		t.Fatalf("shard allocates %v times per call", allocs)
+	}
+}
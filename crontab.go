@@ -3,6 +3,7 @@ package lodago
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -18,15 +19,16 @@ type ScheduleType int32
 
 // 定时任务的时间表类型
 const (
-	Yearly        ScheduleType = 1 // 每年
-	Monthly       ScheduleType = 2 // 每月
-	Weekly        ScheduleType = 3 // 每周
-	Daily         ScheduleType = 4 // 每天
-	Hourly        ScheduleType = 5 // 每小时
-	IntervalMonth ScheduleType = 6 // 每隔几个月
-	IntervalDay   ScheduleType = 7 // 每隔几天
-	Every         ScheduleType = 8 // 间隔时间，只支持[时][分]
-	Once          ScheduleType = 9 // 一次性
+	Yearly        ScheduleType = 1  // 每年
+	Monthly       ScheduleType = 2  // 每月
+	Weekly        ScheduleType = 3  // 每周
+	Daily         ScheduleType = 4  // 每天
+	Hourly        ScheduleType = 5  // 每小时
+	IntervalMonth ScheduleType = 6  // 每隔几个月
+	IntervalDay   ScheduleType = 7  // 每隔几天
+	Every         ScheduleType = 8  // 间隔时间，只支持[时][分]
+	Once          ScheduleType = 9  // 一次性
+	Lunar         ScheduleType = 10 // 每年农历的某月某日
 )
 
 // Job 任务
@@ -37,6 +39,7 @@ type Crontab struct {
 	cron     *cron.Cron
 	entryIDs map[string]cron.EntryID
 	locker   sync.RWMutex
+	metrics  *crontabMetrics
 }
 
 // NewCrontab 创建定时器
@@ -45,6 +48,7 @@ func NewCrontab() *Crontab {
 		cron.New(),
 		make(map[string]cron.EntryID),
 		sync.RWMutex{},
+		newCrontabMetrics(),
 	}
 }
 
@@ -60,23 +64,72 @@ func (c *Crontab) Stop() {
 
 // AddJob 添加任务，返回值是job id，可以用于删除任务
 func (c *Crontab) AddJob(cronTime *CronTime, job Job) (cron.EntryID, error) {
-	spec, err := cronTime.ToSpec()
+	return c.addJob(cronTime, func() error {
+		job()
+		return nil
+	})
+}
+
+// addJob 添加任务，任务返回错误时记为一次失败
+func (c *Crontab) addJob(cronTime *CronTime, job func() error) (cron.EntryID, error) {
+	schedule, err := cronTime.ToSchedule()
 	if err != nil {
 		return 0, err
 	}
 	cronTime.Key = RandString(12) // 12位的随机数字+大小写字母
-	id, err := c.cron.AddFunc(spec, c.jobDecorate(*cronTime, job))
-	if err != nil {
-		c.RemoveJob(id)
-		return 0, err
-	}
+	id := c.cron.Schedule(schedule, cron.FuncJob(c.jobDecorate(*cronTime, c.instrument(cronTime.Key, job))))
 	c.setEntryID(cronTime.Key, id)
 	return id, nil
 }
 
-// RemoveJob 删除一个任务
+// AddJobs 添加一个拥有多个时间表的任务，所有时间表的触发时间合并到同一个key下，
+// 多个时间表在同一时刻触发时只执行一次，返回值是job id，可以用于删除任务
+func (c *Crontab) AddJobs(cronTimes []*CronTime, job Job) (cron.EntryID, error) {
+	if len(cronTimes) == 0 {
+		return 0, errors.New("Cron times is empty")
+	}
+	schedules := make(multiSchedule, 0, len(cronTimes))
+	for _, cronTime := range cronTimes {
+		schedule, err := cronTime.ToSchedule()
+		if err != nil {
+			return 0, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	key := RandString(12)
+	for _, cronTime := range cronTimes {
+		cronTime.Key = key
+	}
+	run := c.instrument(key, func() error {
+		job()
+		return nil
+	})
+	id := c.cron.Schedule(schedules, cron.FuncJob(func() {
+		run()
+		// 所有时间表都不会再触发时（例如全部是已执行的一次性时间），删除这个job
+		if schedules.Next(time.Now()).IsZero() {
+			c.RemoveJobByKey(key)
+		}
+	}))
+	c.setEntryID(key, id)
+	return id, nil
+}
+
+// RemoveJob 删除一个任务，同时删除任务的key和指标
 func (c *Crontab) RemoveJob(id cron.EntryID) {
 	c.cron.Remove(id)
+	if key, ok := c.getEntryKey(id); ok {
+		c.rmEntryID(key)
+	}
+}
+
+// RemoveJobByKey 根据CronTime的key删除任务
+func (c *Crontab) RemoveJobByKey(key string) {
+	id, ok := c.getEntryID(key)
+	if ok {
+		c.cron.Remove(id)
+		c.rmEntryID(key)
+	}
 }
 
 // GetEntries 获得所有实体
@@ -94,12 +147,8 @@ func (c *Crontab) jobDecorate(cronTime CronTime, job Job) Job {
 			// 是一个正值，所以得出结论：
 			//  【时间差为正数代表需要执行，负数为不执行】
 			if cronTime.isEver() {
-				job() // 原先任务正常执行
-				id, ok := c.getEntryID(cronTime.Key)
-				if ok {
-					c.RemoveJob(id)           // 删除这个job
-					c.rmEntryID(cronTime.Key) // 删除这个key
-				}
+				job()                          // 原先任务正常执行
+				c.RemoveJobByKey(cronTime.Key) // 删除这个job和key
 			}
 		}
 	}
@@ -113,11 +162,12 @@ func (c *Crontab) setEntryID(key string, id cron.EntryID) {
 	c.locker.Unlock()
 }
 
-// 移除id
+// 移除id，同时移除这个key的指标
 func (c *Crontab) rmEntryID(key string) {
 	c.locker.Lock()
 	delete(c.entryIDs, key)
 	c.locker.Unlock()
+	c.metrics.remove(key)
 }
 
 // 移除id
@@ -128,6 +178,169 @@ func (c *Crontab) getEntryID(key string) (cron.EntryID, bool) {
 	return id, ok
 }
 
+// 根据id查找key
+func (c *Crontab) getEntryKey(id cron.EntryID) (string, bool) {
+	c.locker.RLock()
+	defer c.locker.RUnlock()
+	for key, entryID := range c.entryIDs {
+		if entryID == id {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// Collect 收集定时任务的指标，实现Collector接口
+func (c *Crontab) Collect() []Metric {
+	c.locker.RLock()
+	ids := make(map[string]cron.EntryID, len(c.entryIDs))
+	for key, id := range c.entryIDs {
+		ids[key] = id
+	}
+	c.locker.RUnlock()
+	now := time.Now()
+	next := Metric{
+		Name: "lodago_crontab_job_next_run_seconds",
+		Help: "Seconds until the next run of the job, negative when the run is overdue.",
+		Type: GaugeMetric,
+	}
+	for key, id := range ids {
+		if t := c.cron.Entry(id).Next; !t.IsZero() {
+			next.Samples = append(next.Samples, MetricSample{
+				Labels: map[string]string{"job": key},
+				Value:  t.Sub(now).Seconds(),
+			})
+		}
+	}
+	sort.Slice(next.Samples, func(i, j int) bool {
+		return next.Samples[i].Labels["job"] < next.Samples[j].Labels["job"]
+	})
+	metrics := c.metrics.collect()
+	return append(metrics, next, Metric{
+		Name:    "lodago_crontab_jobs",
+		Help:    "Number of scheduled jobs.",
+		Type:    GaugeMetric,
+		Samples: []MetricSample{{Value: float64(len(ids))}},
+	})
+}
+
+// errJobSkipped 任务返回这个错误时本次触发记为跳过，不算成功也不算失败
+var errJobSkipped = errors.New("Job is skipped")
+
+// errJobPanicked 任务panic时记为失败
+var errJobPanicked = errors.New("Job panicked")
+
+// instrument 记录任务的执行次数、成功失败跳过、耗时和相对计划时间的延迟，
+// 任务panic时记为失败，然后继续panic
+func (c *Crontab) instrument(key string, job func() error) Job {
+	return func() {
+		start := time.Now()
+		jm := c.metrics.job(key)
+		if id, ok := c.getEntryID(key); ok {
+			// cron在启动任务之前将Prev设置为本次计划的执行时间
+			if prev := c.cron.Entry(id).Prev; !prev.IsZero() && start.After(prev) {
+				jm.lag.Observe(start.Sub(prev).Seconds())
+			}
+		}
+		err := errJobPanicked
+		defer func() {
+			if err == errJobSkipped {
+				jm.skipped.Inc()
+				c.metrics.skipped.Inc()
+				return
+			}
+			jm.duration.Observe(time.Since(start).Seconds())
+			if err == nil {
+				jm.success.Inc()
+				c.metrics.success.Inc()
+			} else {
+				jm.failure.Inc()
+				c.metrics.failure.Inc()
+			}
+		}()
+		err = job()
+	}
+}
+
+// 单个任务的指标
+type jobMetrics struct {
+	success  Counter
+	failure  Counter
+	skipped  Counter
+	duration *Histogram
+	lag      *Histogram
+}
+
+// 定时任务的指标
+type crontabMetrics struct {
+	success Counter // 所有任务累计的成功次数，任务删除后也不会减少
+	failure Counter
+	skipped Counter
+	jobs    map[string]*jobMetrics
+	locker  sync.Mutex
+}
+
+func newCrontabMetrics() *crontabMetrics {
+	return &crontabMetrics{jobs: make(map[string]*jobMetrics)}
+}
+
+// 获取任务的指标，不存在时创建
+func (m *crontabMetrics) job(key string) *jobMetrics {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	jm, found := m.jobs[key]
+	if !found {
+		jm = &jobMetrics{duration: NewHistogram(), lag: NewHistogram()}
+		m.jobs[key] = jm
+	}
+	return jm
+}
+
+// 删除任务的指标，避免一次性任务的指标无限增长
+func (m *crontabMetrics) remove(key string) {
+	m.locker.Lock()
+	delete(m.jobs, key)
+	m.locker.Unlock()
+}
+
+func (m *crontabMetrics) collect() []Metric {
+	m.locker.Lock()
+	keys := make([]string, 0, len(m.jobs))
+	for key := range m.jobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	jobs := make([]*jobMetrics, len(keys))
+	for i, key := range keys {
+		jobs[i] = m.jobs[key]
+	}
+	m.locker.Unlock()
+
+	runs := Metric{Name: "lodago_crontab_job_runs_total", Help: "Number of job runs by job key and status.", Type: CounterMetric}
+	duration := Metric{Name: "lodago_crontab_job_duration_seconds", Help: "Duration of job runs.", Type: HistogramMetric}
+	lag := Metric{Name: "lodago_crontab_job_lag_seconds", Help: "Delay between the scheduled time and the actual start of job runs.", Type: HistogramMetric}
+	for i, jm := range jobs {
+		labels := map[string]string{"job": keys[i]}
+		runs.Samples = append(runs.Samples,
+			MetricSample{Labels: map[string]string{"job": keys[i], "status": "success"}, Value: float64(jm.success.Value())},
+			MetricSample{Labels: map[string]string{"job": keys[i], "status": "failure"}, Value: float64(jm.failure.Value())},
+			MetricSample{Labels: map[string]string{"job": keys[i], "status": "skipped"}, Value: float64(jm.skipped.Value())})
+		duration.Samples = append(duration.Samples, jm.duration.Sample(labels))
+		lag.Samples = append(lag.Samples, jm.lag.Sample(labels))
+	}
+	total := Metric{
+		Name: "lodago_crontab_runs_total",
+		Help: "Number of job runs of all jobs by status, including removed jobs.",
+		Type: CounterMetric,
+		Samples: []MetricSample{
+			{Labels: map[string]string{"status": "success"}, Value: float64(m.success.Value())},
+			{Labels: map[string]string{"status": "failure"}, Value: float64(m.failure.Value())},
+			{Labels: map[string]string{"status": "skipped"}, Value: float64(m.skipped.Value())},
+		},
+	}
+	return []Metric{total, runs, duration, lag}
+}
+
 // CronTime 时间结构
 type CronTime struct {
 	Type   ScheduleType `json:"type"`
@@ -138,6 +351,7 @@ type CronTime struct {
 	Minute string       `json:"minute"`
 	Week   string       `json:"week"`
 	Key    string       `json:"key"`
+	Leap   bool         `json:"leap,omitempty"` // 农历时间表的月份是否为闰月
 }
 
 // ToSpec 转换成spec函数
@@ -151,6 +365,7 @@ type CronTime struct {
 // 【每隔几天】 输入[日][时][分] -- 30 22 */3 * * 每隔3天的22点30分执行
 // 【每隔小时】 输入[时][分] -- @every 1h30m 每隔1小时30分执行
 // 【一次性】 输入[年][月][日][时][分] 由于cron不支持一次性任务，所以只能通过周期性时间删除自身解决。
+// 【农历】 输入农历[月][日][时][分]，无法用spec表示，请使用ToSchedule。
 func (c *CronTime) ToSpec() (string, error) {
 	switch c.Type {
 	case Yearly:
@@ -198,11 +413,85 @@ func (c *CronTime) ToSpec() (string, error) {
 			return "", errors.New("Time format is error")
 		}
 		return fmt.Sprintf("%s %s %s %s *", c.Minute, c.Hour, c.Day, c.Month), nil
+	case Lunar:
+		return "", errors.New("Lunar schedule can not convert to spec")
 	default:
 		return "", errors.New("Schedule type is error")
 	}
 }
 
+// ToSchedule 转换成cron的时间表，一次性时间转换成只触发一次的时间表，农历时间转换成农历时间表
+func (c *CronTime) ToSchedule() (cron.Schedule, error) {
+	if c.Type == Lunar {
+		schedule, err := c.toLunarSchedule()
+		if err != nil {
+			return nil, err
+		}
+		return schedule, nil
+	}
+	spec, err := c.ToSpec()
+	if err != nil {
+		return nil, err
+	}
+	if c.Type == Once {
+		return onceSchedule(c.onceTime()), nil
+	}
+	return cron.ParseStandard(spec)
+}
+
+// Describe 人类可读的时间描述，例如 every day at 22:30
+func (c *CronTime) Describe() (string, error) {
+	if _, err := c.ToSchedule(); err != nil {
+		return "", err
+	}
+	at := fmt.Sprintf("%02s:%02s", c.Hour, c.Minute)
+	switch c.Type {
+	case Yearly:
+		return fmt.Sprintf("every year on %s %s at %s", monthName(c.Month), c.Day, at), nil
+	case Monthly:
+		return fmt.Sprintf("every month on day %s at %s", c.Day, at), nil
+	case Weekly:
+		return fmt.Sprintf("every %s at %s", weekdayName(c.Week), at), nil
+	case Daily:
+		return fmt.Sprintf("every day at %s", at), nil
+	case Hourly:
+		return fmt.Sprintf("every hour at minute %s", c.Minute), nil
+	case IntervalMonth:
+		return fmt.Sprintf("every %s months on day %s at %s", c.Month, c.Day, at), nil
+	case IntervalDay:
+		return fmt.Sprintf("every %s days at %s", c.Day, at), nil
+	case Every:
+		return fmt.Sprintf("every %sh%sm", c.Hour, c.Minute), nil
+	case Once:
+		return fmt.Sprintf("once at %s-%02s-%02s %s", c.Year, c.Month, c.Day, at), nil
+	case Lunar:
+		leap := ""
+		if c.Leap {
+			leap = "leap "
+		}
+		return fmt.Sprintf("every year on lunar %smonth %s day %s at %s", leap, c.Month, c.Day, at), nil
+	}
+	return "", errors.New("Schedule type is error")
+}
+
+// 月份名称
+func monthName(month string) string {
+	m, _ := strconv.Atoi(month)
+	if m < 1 || m > 12 {
+		return "month " + month
+	}
+	return time.Month(m).String()
+}
+
+// 星期名称，0和7都是星期日
+func weekdayName(week string) string {
+	w, _ := strconv.Atoi(week)
+	if w < 0 || w > 7 {
+		return "weekday " + week
+	}
+	return time.Weekday(w % 7).String()
+}
+
 // 判断一些字符串是否都是整数
 func (c *CronTime) isNums(strs ...string) bool {
 	for _, str := range strs {
@@ -216,15 +505,51 @@ func (c *CronTime) isNums(strs ...string) bool {
 // 判断时间是否已经过去，例如 CronTime 中的时间比现在的时间要早
 func (c *CronTime) isEver() bool {
 	t1 := time.Now()
+	t2 := c.onceTime()
+	sub := t1.Sub(t2)
+	if sub.Seconds() >= 0 {
+		return true
+	}
+	return false
+}
+
+// 一次性时间对应的具体时刻
+func (c *CronTime) onceTime() time.Time {
 	year, _ := strconv.Atoi(c.Year)
 	month, _ := strconv.Atoi(c.Month)
 	day, _ := strconv.Atoi(c.Day)
 	hour, _ := strconv.Atoi(c.Hour)
 	minute, _ := strconv.Atoi(c.Minute)
-	t2 := time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.Local)
-	sub := t1.Sub(t2)
-	if sub.Seconds() >= 0 {
-		return true
+	return time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.Local)
+}
+
+// onceSchedule 只触发一次的时间表
+type onceSchedule time.Time
+
+// Next 时刻已经过去时返回零值，cron不会再执行零值时间的任务
+func (s onceSchedule) Next(t time.Time) time.Time {
+	at := time.Time(s)
+	if at.After(t) {
+		return at
 	}
-	return false
+	return time.Time{}
+}
+
+// multiSchedule 多个时间表合并，下一次触发时间取所有时间表中最早的一个，
+// 多个时间表同时触发时只返回一次，从而实现去重
+type multiSchedule []cron.Schedule
+
+// Next 下一次触发时间，所有时间表都不再触发时返回零值
+func (s multiSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, schedule := range s {
+		n := schedule.Next(t)
+		if n.IsZero() {
+			continue
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
 }
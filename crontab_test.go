@@ -0,0 +1,146 @@
+package lodago
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// findSample 按照名称和标签查找指标的值
+func findSample(metrics []Metric, name string, labels map[string]string) (float64, bool) {
+	for _, metric := range metrics {
+		if metric.Name != name {
+			continue
+		}
+	next:
+		for _, sample := range metric.Samples {
+			for k, v := range labels {
+				if sample.Labels[k] != v {
+					continue next
+				}
+			}
+			return sample.Value, true
+		}
+	}
+	return 0, false
+}
+
+func TestCrontabRemoveJobRemovesMetrics(t *testing.T) {
+	c := NewCrontab()
+	cronTime := &CronTime{Type: Daily, Hour: "1", Minute: "0"}
+	id, err := c.AddJob(cronTime, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.instrument(cronTime.Key, func() error { return nil })()
+	if v, _ := findSample(c.Collect(), "lodago_crontab_jobs", nil); v != 1 {
+		t.Fatalf("jobs = %v, want 1", v)
+	}
+	c.RemoveJob(id)
+	metrics := c.Collect()
+	if v, _ := findSample(metrics, "lodago_crontab_jobs", nil); v != 0 {
+		t.Fatalf("jobs after RemoveJob = %v, want 0", v)
+	}
+	if _, found := findSample(metrics, "lodago_crontab_job_runs_total", map[string]string{"job": cronTime.Key}); found {
+		t.Fatal("per-job series still present after RemoveJob")
+	}
+	if v, _ := findSample(metrics, "lodago_crontab_runs_total", map[string]string{"status": "success"}); v != 1 {
+		t.Fatalf("total success = %v, want 1", v)
+	}
+}
+
+func TestCrontabAddJobsCollapsesDuplicateSchedules(t *testing.T) {
+	c := NewCrontab()
+	runs := 0
+	cronTimes := []*CronTime{
+		{Type: Daily, Hour: "10", Minute: "0"},
+		{Type: Hourly, Minute: "0"},
+		{Type: Daily, Hour: "10", Minute: "0"}, // 重复的时间表
+	}
+	id, err := c.AddJobs(cronTimes, func() { runs++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.GetEntries()) != 1 {
+		t.Fatalf("entries = %d, want 1", len(c.GetEntries()))
+	}
+	key := cronTimes[0].Key
+	for _, cronTime := range cronTimes {
+		if cronTime.Key != key {
+			t.Fatalf("keys = %s, %s, want the same key", key, cronTime.Key)
+		}
+	}
+	// 10点三个时间表同时触发，只返回一次
+	schedule := c.cron.Entry(id).Schedule
+	next := time.Date(2030, time.January, 1, 9, 30, 0, 0, time.Local)
+	for hour := 10; hour < 34; hour++ {
+		next = schedule.Next(next)
+		if want := time.Date(2030, time.January, 1, hour, 0, 0, 0, time.Local); !next.Equal(want) {
+			t.Fatalf("Next = %v, want %v", next, want)
+		}
+	}
+	c.cron.Entry(id).Job.Run()
+	if v, _ := findSample(c.Collect(), "lodago_crontab_job_runs_total", map[string]string{"job": key, "status": "success"}); runs != 1 || v != 1 {
+		t.Fatalf("runs = %d, success = %v, want 1", runs, v)
+	}
+}
+
+func TestCrontabAddJobsRemovesFinishedOnceJob(t *testing.T) {
+	once := func(at time.Time) *CronTime {
+		return &CronTime{
+			Type:   Once,
+			Year:   strconv.Itoa(at.Year()),
+			Month:  strconv.Itoa(int(at.Month())),
+			Day:    strconv.Itoa(at.Day()),
+			Hour:   strconv.Itoa(at.Hour()),
+			Minute: strconv.Itoa(at.Minute()),
+		}
+	}
+	// 一次性时间不能是过去的时间，添加之后把时间表改成已经过去的时刻，模拟时间已经触发
+	expire := func(c *Crontab, id cron.EntryID, idx int) {
+		schedules := c.cron.Entry(id).Schedule.(multiSchedule)
+		schedules[idx] = onceSchedule(time.Now().Add(-time.Minute))
+	}
+	now := time.Now()
+	c := NewCrontab()
+	runs := 0
+	job := func() { runs++ }
+
+	// 还有没触发的时间时保留任务
+	pending, err := c.AddJobs([]*CronTime{once(now.Add(time.Hour)), once(now.Add(2 * time.Hour))}, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expire(c, pending, 0)
+	c.cron.Entry(pending).Job.Run()
+	if c.cron.Entry(pending).ID != pending {
+		t.Fatal("job with a pending once schedule was removed")
+	}
+
+	// 所有一次性时间都已经触发之后删除任务，同时删除key和指标
+	cronTimes := []*CronTime{once(now.Add(time.Hour)), once(now.Add(2 * time.Hour))}
+	done, err := c.AddJobs(cronTimes, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expire(c, done, 0)
+	expire(c, done, 1)
+	c.cron.Entry(done).Job.Run()
+	if runs != 2 {
+		t.Fatalf("runs = %d, want 2", runs)
+	}
+	if c.cron.Entry(done).ID != 0 {
+		t.Fatal("finished once job is still scheduled")
+	}
+	if _, ok := c.getEntryID(cronTimes[0].Key); ok {
+		t.Fatal("finished once job key is still registered")
+	}
+	if _, found := findSample(c.Collect(), "lodago_crontab_job_runs_total", map[string]string{"job": cronTimes[0].Key}); found {
+		t.Fatal("finished once job metrics are still present")
+	}
+	if len(c.GetEntries()) != 1 {
+		t.Fatalf("entries = %d, want 1", len(c.GetEntries()))
+	}
+}
@@ -0,0 +1,180 @@
+package lodago
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 延迟队列，元素在指定的时间之后才能被取出，可以在取出之前根据id取消。
+// 时间来自Clock接口，测试时可以注入自己实现的时钟。
+// 关闭之后不能再插入，和Queue一样，剩余的元素仍然可以在到期之后取出，取完之后Take返回ErrQueueClosed。
+
+// Clock 时钟接口
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// 系统时钟
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock 使用time包的系统时钟
+var SystemClock Clock = systemClock{}
+
+// 延迟队列中的元素
+type delayItem[T any] struct {
+	id    string
+	value T
+	at    time.Time
+}
+
+// DelayQueue 延迟队列
+type DelayQueue[T any] struct {
+	clock    Clock
+	pq       *PriorityQueue[*delayItem[T]]
+	items    map[string]*PriorityItem[*delayItem[T]]
+	closed   bool
+	locker   sync.Mutex
+	notEmpty qnotifier // 有新元素、元素被取消或者队列关闭时唤醒Take重新计算等待时间
+}
+
+// NewDelayQueue 创建延迟队列，clock为空时使用系统时钟
+func NewDelayQueue[T any](clock ...Clock) *DelayQueue[T] {
+	q := &DelayQueue[T]{
+		clock: SystemClock,
+		items: make(map[string]*PriorityItem[*delayItem[T]]),
+		pq: NewPriorityQueue(func(a, b *delayItem[T]) bool {
+			return a.at.Before(b.at)
+		}),
+	}
+	if len(clock) > 0 && clock[0] != nil {
+		q.clock = clock[0]
+	}
+	return q
+}
+
+// Len 队列中元素的数量，包括还没有到期的元素
+func (q *DelayQueue[T]) Len() int {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.pq.Len()
+}
+
+// Offer 插入元素，delay之后可以取出，返回元素的id
+func (q *DelayQueue[T]) Offer(value T, delay time.Duration) string {
+	return q.OfferAt(value, q.clock.Now().Add(delay))
+}
+
+// OfferAt 插入元素，在at时间之后可以取出，返回元素的id，队列已经关闭时不插入，返回空字符串
+func (q *DelayQueue[T]) OfferAt(value T, at time.Time) string {
+	q.locker.Lock()
+	if q.closed {
+		q.locker.Unlock()
+		return ""
+	}
+	id := RandString(12) // 12位的随机数字+大小写字母
+	for q.items[id] != nil {
+		id = RandString(12)
+	}
+	q.items[id] = q.pq.Push(&delayItem[T]{id: id, value: value, at: at})
+	q.locker.Unlock()
+	q.notEmpty.broadcast()
+	return id
+}
+
+// Cancel 根据id取消还没有被取出的元素，返回是否取消成功
+func (q *DelayQueue[T]) Cancel(id string) bool {
+	q.locker.Lock()
+	item, found := q.items[id]
+	if found {
+		delete(q.items, id)
+		q.pq.Remove(item)
+	}
+	q.locker.Unlock()
+	if found {
+		q.notEmpty.broadcast()
+	}
+	return found
+}
+
+// Close 关闭队列，之后的插入都会失败，唤醒阻塞的Take，重复关闭没有影响
+func (q *DelayQueue[T]) Close() {
+	q.locker.Lock()
+	q.closed = true
+	q.locker.Unlock()
+	q.notEmpty.broadcast()
+}
+
+// IsClosed 队列是否已经关闭
+func (q *DelayQueue[T]) IsClosed() bool {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.closed
+}
+
+// Poll 取出一个已经到期的元素，没有到期的元素时立即返回false
+func (q *DelayQueue[T]) Poll() (T, bool) {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	value, ok, _ := q.poll()
+	return value, ok
+}
+
+// Take 取出一个已经到期的元素，没有到期的元素时阻塞，直到有元素到期或者ctx结束，
+// 队列关闭并且已经取完时返回ErrQueueClosed
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	for {
+		q.locker.Lock()
+		value, ok, wait := q.poll()
+		if ok {
+			q.locker.Unlock()
+			return value, nil
+		}
+		if q.closed && q.pq.Len() == 0 {
+			q.locker.Unlock()
+			var zero T
+			return zero, ErrQueueClosed
+		}
+		// 在锁内登记，OfferAt、Cancel和Close在释放锁之后通知，不会丢失唤醒
+		ch := q.notEmpty.register()
+		q.locker.Unlock()
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = q.clock.After(wait)
+		}
+		select {
+		case <-ch:
+		case <-timer:
+		case <-ctx.Done():
+			q.notEmpty.unregister()
+			var zero T
+			return zero, ctx.Err()
+		}
+		q.notEmpty.unregister()
+	}
+}
+
+// poll 取出到期的元素，没有到期的元素时返回最早的元素还需要等待多久，队列为空时等待时间为0，
+// 调用者需要持有锁
+func (q *DelayQueue[T]) poll() (T, bool, time.Duration) {
+	var zero T
+	item, ok := q.pq.Peek()
+	if !ok {
+		return zero, false, 0
+	}
+	if wait := item.at.Sub(q.clock.Now()); wait > 0 {
+		return zero, false, wait
+	}
+	q.pq.Pop()
+	delete(q.items, item.id)
+	return item.value, true, 0
+}
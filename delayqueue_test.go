@@ -0,0 +1,226 @@
+package lodago
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟，After返回的通道在Advance到期时触发
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance 推进时间，触发到期的定时器
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// Timers 还没有触发的定时器数量
+func (c *fakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// takeAsync 在协程中调用Take，返回结果的通道
+func takeAsync[T any](ctx context.Context, q *DelayQueue[T]) <-chan delayTakeResult[T] {
+	ch := make(chan delayTakeResult[T], 1)
+	go func() {
+		value, err := q.Take(ctx)
+		ch <- delayTakeResult[T]{value, err}
+	}()
+	return ch
+}
+
+type delayTakeResult[T any] struct {
+	value T
+	err   error
+}
+
+// expectBlocked 确认Take还没有返回
+func expectBlocked[T any](t *testing.T, ch <-chan delayTakeResult[T]) {
+	t.Helper()
+	select {
+	case r := <-ch:
+		t.Fatalf("Take returned early: %v, %v", r.value, r.err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// expectTaken 等待Take返回
+func expectTaken[T any](t *testing.T, ch <-chan delayTakeResult[T]) delayTakeResult[T] {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("Take did not return")
+	}
+	return delayTakeResult[T]{}
+}
+
+func TestDelayQueueDeadlineOrder(t *testing.T) {
+	clock := newFakeClock()
+	q := NewDelayQueue[string](clock)
+	q.Offer("c", 3*time.Second)
+	q.Offer("a", time.Second)
+	q.OfferAt("b", clock.Now().Add(2*time.Second))
+	q.Offer("a2", time.Second) // 和a同时到期，按照插入顺序
+	if _, ok := q.Poll(); ok {
+		t.Fatal("Poll returned an item before its deadline")
+	}
+	var got []string
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		for {
+			value, ok := q.Poll()
+			if !ok {
+				break
+			}
+			got = append(got, value)
+		}
+	}
+	want := []string{"a", "a2", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("Poll order = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Poll order = %v, want %v", got, want)
+		}
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Len = %d, want 0", q.Len())
+	}
+}
+
+func TestDelayQueueTakeBlocksUntilDeadline(t *testing.T) {
+	clock := newFakeClock()
+	q := NewDelayQueue[string](clock)
+	q.Offer("late", time.Second)
+	result := takeAsync(context.Background(), q)
+	waitFor(t, "Take to wait on the clock", func() bool { return clock.Timers() == 1 })
+	clock.Advance(500 * time.Millisecond)
+	expectBlocked(t, result)
+
+	// 插入更早到期的元素，Take重新计算等待时间
+	q.Offer("early", 100*time.Millisecond)
+	waitFor(t, "Take to wait on the new deadline", func() bool { return clock.Timers() == 2 })
+	expectBlocked(t, result)
+	clock.Advance(100 * time.Millisecond)
+	if r := expectTaken(t, result); r.err != nil || r.value != "early" {
+		t.Fatalf("Take = %q, %v, want early", r.value, r.err)
+	}
+
+	result = takeAsync(context.Background(), q)
+	expectBlocked(t, result)
+	clock.Advance(400 * time.Millisecond)
+	if r := expectTaken(t, result); r.err != nil || r.value != "late" {
+		t.Fatalf("Take = %q, %v, want late", r.value, r.err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result = takeAsync(ctx, q)
+	expectBlocked(t, result)
+	cancel()
+	if r := expectTaken(t, result); r.err != context.Canceled {
+		t.Fatalf("Take after cancel = %v, want context.Canceled", r.err)
+	}
+}
+
+func TestDelayQueueCancel(t *testing.T) {
+	clock := newFakeClock()
+	q := NewDelayQueue[string](clock)
+	first := q.Offer("first", time.Second)
+	q.Offer("second", 2*time.Second)
+	result := takeAsync(context.Background(), q)
+	waitFor(t, "Take to wait on the clock", func() bool { return clock.Timers() == 1 })
+
+	// 取消Take正在等待的元素，Take改为等待下一个元素
+	if !q.Cancel(first) {
+		t.Fatal("Cancel of a pending item failed")
+	}
+	if q.Cancel(first) || q.Cancel("unknown") {
+		t.Fatal("Cancel of a cancelled or unknown id succeeded")
+	}
+	waitFor(t, "Take to wait on the next deadline", func() bool { return clock.Timers() == 2 })
+	clock.Advance(time.Second)
+	expectBlocked(t, result)
+	clock.Advance(time.Second)
+	if r := expectTaken(t, result); r.err != nil || r.value != "second" {
+		t.Fatalf("Take = %q, %v, want second", r.value, r.err)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Len = %d, want 0", q.Len())
+	}
+}
+
+func TestDelayQueueCloseWakesTake(t *testing.T) {
+	clock := newFakeClock()
+	q := NewDelayQueue[string](clock)
+	result := takeAsync(context.Background(), q)
+	waitForWaiters(t, &q.notEmpty)
+	q.Close()
+	if r := expectTaken(t, result); r.err != ErrQueueClosed {
+		t.Fatalf("Take after Close = %v, want ErrQueueClosed", r.err)
+	}
+	if !q.IsClosed() || q.Offer("x", 0) != "" || q.Len() != 0 {
+		t.Fatal("Offer succeeded after Close")
+	}
+
+	// 关闭之前插入的元素仍然在到期之后取出
+	q = NewDelayQueue[string](clock)
+	q.Offer("pending", time.Second)
+	q.Close()
+	result = takeAsync(context.Background(), q)
+	expectBlocked(t, result)
+	clock.Advance(time.Second)
+	if r := expectTaken(t, result); r.err != nil || r.value != "pending" {
+		t.Fatalf("Take = %q, %v, want pending", r.value, r.err)
+	}
+	if _, err := q.Take(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("Take on drained queue = %v, want ErrQueueClosed", err)
+	}
+}
@@ -0,0 +1,120 @@
+package lodago
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// 有界的工作窃取双端队列（Chase-Lev），拥有者在底部插入和取出（后进先出），
+// 其他协程从顶部窃取（先进先出）。Push和Pop只能在拥有者协程调用，Steal可以在任意协程并发调用。
+// 位置上保存值的指针，读写都是原子的，窃取时读到的值在CAS成功之后才有效。
+
+// Deque 元素类型为interface{}的工作窃取双端队列
+type Deque = DequeOf[interface{}]
+
+// DequeOf 元素类型为T的工作窃取双端队列
+type DequeOf[T any] struct {
+	_      [cacheLinePad]byte
+	top    int64 // 窃取者竞争写入
+	_      [cacheLinePad - 8]byte
+	bottom int64 // 拥有者写入
+	_      [cacheLinePad - 8]byte
+	// 创建之后只读的成员
+	capacity uint64
+	capMod   uint64
+	slots    []atomic.Pointer[T]
+}
+
+// NewDeque 创建一个工作窃取双端队列，容量向上取整为2的次方
+func NewDeque(capacity uint64) *Deque {
+	return NewDequeOf[interface{}](capacity)
+}
+
+// NewDequeOf 创建一个元素类型为T的工作窃取双端队列
+func NewDequeOf[T any](capacity uint64) *DequeOf[T] {
+	d := new(DequeOf[T])
+	d.capacity = minQuantity(capacity)
+	d.capMod = d.capacity - 1
+	d.slots = make([]atomic.Pointer[T], d.capacity)
+	return d
+}
+
+// ToString 序列化成字符串
+func (d *DequeOf[T]) ToString() string {
+	return fmt.Sprintf("Deque{capacity: %v, capMod: %v, top: %v, bottom: %v}",
+		d.capacity, d.capMod, atomic.LoadInt64(&d.top), atomic.LoadInt64(&d.bottom))
+}
+
+// GetCapacity 获取容量
+func (d *DequeOf[T]) GetCapacity() uint64 {
+	return d.capacity
+}
+
+// GetQuantity 获取当前队列剩余多少条记录
+func (d *DequeOf[T]) GetQuantity() uint64 {
+	top := atomic.LoadInt64(&d.top)
+	bottom := atomic.LoadInt64(&d.bottom)
+	if bottom > top {
+		return uint64(bottom - top)
+	}
+	return 0
+}
+
+// Push 在底部插入一条记录，只能在拥有者协程调用，队列已满时返回false
+func (d *DequeOf[T]) Push(value T) bool {
+	bottom := atomic.LoadInt64(&d.bottom)
+	top := atomic.LoadInt64(&d.top)
+	if uint64(bottom-top) >= d.capacity {
+		return false
+	}
+	d.slots[uint64(bottom)&d.capMod].Store(&value)
+	atomic.StoreInt64(&d.bottom, bottom+1) // 发布写入的记录
+	return true
+}
+
+// Pop 从底部取出最后插入的记录，只能在拥有者协程调用，队列为空或者最后一条被窃取时返回false
+func (d *DequeOf[T]) Pop() (T, bool) {
+	var zero T
+	bottom := atomic.LoadInt64(&d.bottom) - 1
+	atomic.StoreInt64(&d.bottom, bottom) // 先预定底部的位置，之后的窃取不会越过它
+	top := atomic.LoadInt64(&d.top)
+	if top > bottom { // 队列为空
+		atomic.StoreInt64(&d.bottom, bottom+1)
+		return zero, false
+	}
+	slot := &d.slots[uint64(bottom)&d.capMod]
+	value := slot.Load()
+	if top == bottom { // 只剩最后一条，和窃取者竞争
+		won := atomic.CompareAndSwapInt64(&d.top, top, top+1)
+		atomic.StoreInt64(&d.bottom, bottom+1)
+		if !won {
+			return zero, false
+		}
+	}
+	slot.CompareAndSwap(value, nil) // 释放引用
+	return *value, true
+}
+
+// Steal 从顶部窃取最早插入的记录，可以在任意协程并发调用，队列为空或者竞争失败时返回false
+func (d *DequeOf[T]) Steal() (T, bool) {
+	var zero T
+	var bo qbackoff
+	for retry := 0; ; retry++ {
+		top := atomic.LoadInt64(&d.top)
+		bottom := atomic.LoadInt64(&d.bottom)
+		if top >= bottom {
+			return zero, false
+		}
+		slot := &d.slots[uint64(top)&d.capMod]
+		value := slot.Load()
+		if atomic.CompareAndSwapInt64(&d.top, top, top+1) {
+			// 拥有者可能已经在这个位置写入了下一轮的记录，只有还是原来的值时才释放
+			slot.CompareAndSwap(value, nil)
+			return *value, true
+		}
+		if retry >= qCASRetries {
+			return zero, false
+		}
+		bo.pause()
+	}
+}
@@ -0,0 +1,73 @@
+package lodago
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// dequeStress 拥有者插入并取出，thieves个协程同时窃取，检查每个值恰好被取走一次。
+// batch为拥有者每次连续插入的数量，为1时每次都在最后一条记录上和窃取者竞争
+func dequeStress(t *testing.T, thieves, batch int) {
+	t.Helper()
+	total := stressCount() * 4
+	d := NewDequeOf[int](64)
+	seen := make([]int32, total)
+	var taken int64
+	take := func(v int) {
+		atomic.AddInt32(&seen[v], 1)
+		atomic.AddInt64(&taken, 1)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < thieves; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&taken) < int64(total) {
+				if v, ok := d.Steal(); ok {
+					take(v)
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	for next := 0; next < total; {
+		for i := 0; i < batch && next < total; i++ {
+			if !d.Push(next) {
+				break
+			}
+			next++
+		}
+		if v, ok := d.Pop(); ok {
+			take(v)
+		}
+	}
+	for {
+		v, ok := d.Pop()
+		if !ok {
+			break
+		}
+		take(v)
+	}
+	wg.Wait()
+	for v, count := range seen {
+		if count != 1 {
+			t.Fatalf("value %d taken %d times", v, count)
+		}
+	}
+	if n := d.GetQuantity(); n != 0 {
+		t.Fatalf("quantity = %d after draining, want 0", n)
+	}
+}
+
+// 用go test -race运行
+func TestDequeStress(t *testing.T) {
+	for _, tc := range []struct{ thieves, batch int }{
+		{1, 1}, {4, 1}, // 只有一条记录，Pop和Steal竞争最后一条
+		{4, 3}, {8, 16},
+	} {
+		dequeStress(t, tc.thieves, tc.batch)
+	}
+}
@@ -0,0 +1,530 @@
+package lodago
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	json "github.com/json-iterator/go"
+)
+
+// 基于文件的持久化队列，记录追加写入分段文件，每条记录为4字节长度 + 4字节crc32 + 数据，
+// 消费者取出记录之后需要Ack，已经确认的位置保存在meta文件中，重启之后从第一条没有确认的记录继续（至少一次）。
+// 进程崩溃时最后一条记录可能只写了一部分，打开时会截断分段文件末尾不完整的记录。
+
+// ErrDiskQueueCorrupted 分段文件中的记录校验失败
+var ErrDiskQueueCorrupted = errors.New("DiskQueue record is corrupted")
+
+// Codec 队列记录的编码方式
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// GobCodec 使用encoding/gob编码
+type GobCodec[T any] struct{}
+
+// Encode 编码
+func (GobCodec[T]) Encode(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode 解码
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// JSONCodec 使用json编码
+type JSONCodec[T any] struct{}
+
+// Encode 编码
+func (JSONCodec[T]) Encode(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Decode 解码
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// DiskQueueOptions 持久化队列的选项
+type DiskQueueOptions struct {
+	SegmentSize int64 // 分段文件的大小，超过之后写入新的分段，默认64MB
+	Sync        bool  // 每次写入和确认之后是否调用fsync，关闭时只能保证进程崩溃不丢数据，不能保证系统崩溃不丢数据
+}
+
+// 默认的分段文件大小
+const defaultSegmentSize = 64 << 20
+
+// 记录头的长度，4字节长度 + 4字节crc32
+const dqHeaderSize = 8
+
+// 分段文件和meta文件的名称
+const (
+	dqSegmentExt = ".seg"
+	dqMetaFile   = "meta"
+)
+
+// 分段文件，文件名为第一条记录的位置
+type dqSegment struct {
+	base  uint64 // 第一条记录的位置
+	count uint64 // 记录数量
+	size  int64  // 文件大小
+	path  string
+}
+
+// DiskQueue 持久化队列，线程安全
+type DiskQueue[T any] struct {
+	dir      string
+	codec    Codec[T]
+	options  DiskQueueOptions
+	segments []*dqSegment
+	writer   *os.File // 最后一个分段的追加写入
+	reader   *os.File // readIdx分段的读取
+	readIdx  int      // 正在读取的分段
+	readPos  int64    // 下一条记录在分段文件中的位置
+	writeOff uint64   // 下一条写入记录的位置
+	readOff  uint64   // 下一条取出记录的位置
+	ackOff   uint64   // 小于这个位置的记录已经确认
+	closed   bool
+	locker   sync.Mutex
+	notEmpty qnotifier
+}
+
+// OpenDiskQueue 打开或者创建dir目录下的持久化队列，options为可选的选项
+func OpenDiskQueue[T any](dir string, codec Codec[T], options ...DiskQueueOptions) (*DiskQueue[T], error) {
+	q := &DiskQueue[T]{dir: dir, codec: codec}
+	if len(options) > 0 {
+		q.options = options[0]
+	}
+	if q.options.SegmentSize <= 0 {
+		q.options.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := q.restore(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Put 写入一条记录，返回记录的位置
+func (q *DiskQueue[T]) Put(value T) (uint64, error) {
+	data, err := q.codec.Encode(value)
+	if err != nil {
+		return 0, err
+	}
+	record := make([]byte, dqHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[dqHeaderSize:], data)
+
+	q.locker.Lock()
+	if q.closed {
+		q.locker.Unlock()
+		return 0, ErrQueueClosed
+	}
+	seg := q.segments[len(q.segments)-1]
+	if seg.count > 0 && seg.size+int64(len(record)) > q.options.SegmentSize {
+		if err := q.rotate(); err != nil {
+			q.locker.Unlock()
+			return 0, err
+		}
+		seg = q.segments[len(q.segments)-1]
+	}
+	if _, err := q.writer.Write(record); err != nil {
+		// 写了一部分时截断，保持文件末尾是完整的记录
+		q.writer.Truncate(seg.size)
+		q.locker.Unlock()
+		return 0, err
+	}
+	if q.options.Sync {
+		if err := q.writer.Sync(); err != nil {
+			q.locker.Unlock()
+			return 0, err
+		}
+	}
+	offset := q.writeOff
+	seg.count++
+	seg.size += int64(len(record))
+	q.writeOff++
+	q.locker.Unlock()
+	q.notEmpty.broadcast()
+	return offset, nil
+}
+
+// Get 取出下一条没有取出的记录，返回值、记录的位置和是否成功，队列为空时立即返回false。
+// 取出的记录在Ack之前重启会再次取出。
+func (q *DiskQueue[T]) Get() (T, uint64, bool, error) {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.get()
+}
+
+// GetContext 取出下一条没有取出的记录，队列为空时阻塞，直到有新的记录或者ctx结束，队列关闭时返回ErrQueueClosed
+func (q *DiskQueue[T]) GetContext(ctx context.Context) (T, uint64, error) {
+	for {
+		q.locker.Lock()
+		value, offset, ok, err := q.get()
+		if ok || err != nil {
+			q.locker.Unlock()
+			return value, offset, err
+		}
+		// 在锁内登记，Put在释放锁之后通知，不会丢失唤醒
+		ch := q.notEmpty.register()
+		q.locker.Unlock()
+		select {
+		case <-ch:
+			q.notEmpty.unregister()
+		case <-ctx.Done():
+			q.notEmpty.unregister()
+			return value, 0, ctx.Err()
+		}
+	}
+}
+
+// Ack 确认offset以及之前的所有记录，已经全部确认的分段文件会被删除。
+// 确认是累计的，之前取出但是没有确认的记录也会一起被确认，重启之后不会再次取出
+func (q *DiskQueue[T]) Ack(offset uint64) error {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if offset >= q.readOff {
+		return fmt.Errorf("DiskQueue offset %d has not been read", offset)
+	}
+	if offset < q.ackOff {
+		return nil
+	}
+	q.ackOff = offset + 1
+	if err := q.writeMeta(); err != nil {
+		return err
+	}
+	return q.removeAcked()
+}
+
+// Len 没有确认的记录数量，包括已经取出但是还没有确认的记录
+func (q *DiskQueue[T]) Len() uint64 {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.writeOff - q.ackOff
+}
+
+// Pending 还没有取出的记录数量
+func (q *DiskQueue[T]) Pending() uint64 {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.writeOff - q.readOff
+}
+
+// Close 关闭队列，唤醒所有阻塞的GetContext，之后的操作返回ErrQueueClosed
+func (q *DiskQueue[T]) Close() error {
+	q.locker.Lock()
+	if q.closed {
+		q.locker.Unlock()
+		return nil
+	}
+	q.closed = true
+	err := q.closeFiles()
+	q.locker.Unlock()
+	q.notEmpty.broadcast()
+	return err
+}
+
+// ToString 序列化成字符串
+func (q *DiskQueue[T]) ToString() string {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return fmt.Sprintf("DiskQueue{dir: %v, segments: %v, writeOffset: %v, readOffset: %v, ackOffset: %v, closed: %v}",
+		q.dir, len(q.segments), q.writeOff, q.readOff, q.ackOff, q.closed)
+}
+
+// get 读取下一条记录，调用者需要持有锁
+func (q *DiskQueue[T]) get() (T, uint64, bool, error) {
+	var zero T
+	if q.closed {
+		return zero, 0, false, ErrQueueClosed
+	}
+	if q.readOff >= q.writeOff {
+		return zero, 0, false, nil
+	}
+	// 当前分段已经读完，切换到下一个分段
+	for seg := q.segments[q.readIdx]; q.readOff >= seg.base+seg.count; seg = q.segments[q.readIdx] {
+		if err := q.openReader(q.readIdx + 1); err != nil {
+			return zero, 0, false, err
+		}
+	}
+	var header [dqHeaderSize]byte
+	if _, err := q.reader.ReadAt(header[:], q.readPos); err != nil {
+		return zero, 0, false, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+	if _, err := q.reader.ReadAt(data, q.readPos+dqHeaderSize); err != nil {
+		return zero, 0, false, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(header[4:8]) {
+		return zero, 0, false, ErrDiskQueueCorrupted
+	}
+	offset := q.readOff
+	q.readOff++
+	q.readPos += dqHeaderSize + int64(len(data))
+	// 解码失败时跳过这条记录，调用者可以根据位置确认它
+	value, err := q.codec.Decode(data)
+	if err != nil {
+		return zero, offset, false, fmt.Errorf("DiskQueue offset %d: %w", offset, err)
+	}
+	return value, offset, true, nil
+}
+
+// restore 读取meta和分段文件，截断末尾不完整的记录，恢复读写位置
+func (q *DiskQueue[T]) restore() error {
+	if err := q.readMeta(); err != nil {
+		return err
+	}
+	if err := q.loadSegments(); err != nil {
+		return err
+	}
+	if len(q.segments) == 0 {
+		q.segments = append(q.segments, &dqSegment{base: q.ackOff, path: q.segmentPath(q.ackOff)})
+	}
+	first, last := q.segments[0], q.segments[len(q.segments)-1]
+	q.writeOff = last.base + last.count
+	if q.ackOff < first.base { // 分段文件被删除了
+		q.ackOff = first.base
+	}
+	if q.ackOff > q.writeOff { // meta文件比分段文件新
+		q.ackOff = q.writeOff
+	}
+	q.readOff = q.ackOff
+	writer, err := os.OpenFile(last.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	q.writer = writer
+	// 定位到第一条没有确认的记录
+	if err := q.openReader(0); err != nil {
+		return err
+	}
+	for seg := q.segments[q.readIdx]; q.readOff >= seg.base+seg.count && q.readIdx < len(q.segments)-1; seg = q.segments[q.readIdx] {
+		if err := q.openReader(q.readIdx + 1); err != nil {
+			return err
+		}
+	}
+	for skip := q.readOff - q.segments[q.readIdx].base; skip > 0; skip-- {
+		var header [dqHeaderSize]byte
+		if _, err := q.reader.ReadAt(header[:], q.readPos); err != nil {
+			return err
+		}
+		q.readPos += dqHeaderSize + int64(binary.LittleEndian.Uint32(header[0:4]))
+	}
+	return q.removeAcked()
+}
+
+// loadSegments 扫描分段文件，统计记录数量，最后一个分段末尾不完整的记录会被截断
+func (q *DiskQueue[T]) loadSegments() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, dqSegmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, dqSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, &dqSegment{base: base, path: filepath.Join(q.dir, name)})
+	}
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].base < q.segments[j].base
+	})
+	for i, seg := range q.segments {
+		count, valid, size, err := scanSegment(seg.path)
+		if err != nil {
+			return err
+		}
+		if valid < size {
+			if i < len(q.segments)-1 {
+				return fmt.Errorf("DiskQueue segment %s: %w", seg.path, ErrDiskQueueCorrupted)
+			}
+			if err := os.Truncate(seg.path, valid); err != nil {
+				return err
+			}
+		}
+		seg.count, seg.size = count, valid
+		if i > 0 && seg.base != q.segments[i-1].base+q.segments[i-1].count {
+			return fmt.Errorf("DiskQueue segment %s does not follow the previous segment", seg.path)
+		}
+	}
+	return nil
+}
+
+// scanSegment 校验分段文件中的记录，返回完整记录的数量、完整记录的长度和文件长度
+func scanSegment(path string) (uint64, int64, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var count uint64
+	var pos int64
+	var header [dqHeaderSize]byte
+	for {
+		if _, err := file.ReadAt(header[:], pos); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, 0, 0, err
+		}
+		length := int64(binary.LittleEndian.Uint32(header[0:4]))
+		if pos+dqHeaderSize+length > info.Size() {
+			break
+		}
+		data := make([]byte, length)
+		if _, err := file.ReadAt(data, pos+dqHeaderSize); err != nil {
+			return 0, 0, 0, err
+		}
+		if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(header[4:8]) {
+			break
+		}
+		count++
+		pos += dqHeaderSize + length
+	}
+	return count, pos, info.Size(), nil
+}
+
+// rotate 关闭当前分段，创建新的分段，调用者需要持有锁
+func (q *DiskQueue[T]) rotate() error {
+	seg := &dqSegment{base: q.writeOff, path: q.segmentPath(q.writeOff)}
+	writer, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err := q.writer.Close(); err != nil {
+		writer.Close()
+		return err
+	}
+	q.writer = writer
+	q.segments = append(q.segments, seg)
+	return nil
+}
+
+// openReader 打开第idx个分段用于读取，调用者需要持有锁
+func (q *DiskQueue[T]) openReader(idx int) error {
+	reader, err := os.Open(q.segments[idx].path)
+	if err != nil {
+		return err
+	}
+	if q.reader != nil {
+		q.reader.Close()
+	}
+	q.reader, q.readIdx, q.readPos = reader, idx, 0
+	return nil
+}
+
+// removeAcked 删除已经全部确认并且读取完的分段文件，正在写入的分段不会删除，调用者需要持有锁
+func (q *DiskQueue[T]) removeAcked() error {
+	removed := 0
+	for removed < q.readIdx && removed < len(q.segments)-1 {
+		seg := q.segments[removed]
+		if seg.base+seg.count > q.ackOff {
+			break
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+	}
+	q.segments = q.segments[removed:]
+	q.readIdx -= removed
+	return nil
+}
+
+// readMeta 读取已经确认的位置，meta文件不存在时从0开始
+func (q *DiskQueue[T]) readMeta() error {
+	data, err := os.ReadFile(filepath.Join(q.dir, dqMetaFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) != 12 || crc32.ChecksumIEEE(data[:8]) != binary.LittleEndian.Uint32(data[8:12]) {
+		return fmt.Errorf("DiskQueue meta: %w", ErrDiskQueueCorrupted)
+	}
+	q.ackOff = binary.LittleEndian.Uint64(data[:8])
+	return nil
+}
+
+// writeMeta 先写临时文件再重命名，保证meta文件是完整的，调用者需要持有锁
+func (q *DiskQueue[T]) writeMeta() error {
+	var data [12]byte
+	binary.LittleEndian.PutUint64(data[:8], q.ackOff)
+	binary.LittleEndian.PutUint32(data[8:12], crc32.ChecksumIEEE(data[:8]))
+	path := filepath.Join(q.dir, dqMetaFile)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data[:]); err != nil {
+		file.Close()
+		return err
+	}
+	if q.options.Sync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// segmentPath 分段文件的路径
+func (q *DiskQueue[T]) segmentPath(base uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", base, dqSegmentExt))
+}
+
+// closeFiles 关闭打开的文件
+func (q *DiskQueue[T]) closeFiles() error {
+	var err error
+	if q.reader != nil {
+		q.reader.Close()
+		q.reader = nil
+	}
+	if q.writer != nil {
+		err = q.writer.Close()
+		q.writer = nil
+	}
+	return err
+}
@@ -0,0 +1,219 @@
+package lodago
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lastSegment 返回目录中最后一个分段文件的路径
+func lastSegment(t *testing.T, dir string) string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+dqSegmentExt))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no segment files in %s: %v", dir, err)
+	}
+	sort.Strings(paths)
+	return paths[len(paths)-1]
+}
+
+// 写入记录，确认一部分，在最后一个分段末尾追加一条不完整的记录模拟崩溃，重新打开之后检查顺序和确认位置
+func TestDiskQueueCrashRecovery(t *testing.T) {
+	dir := t.TempDir()
+	options := DiskQueueOptions{SegmentSize: 64} // 每个分段只能放几条记录
+	q, err := OpenDiskQueue[int](dir, GobCodec[int]{}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if offset, err := q.Put(i); err != nil || offset != uint64(i) {
+			t.Fatalf("Put(%d) = %d, %v", i, offset, err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		if v, offset, ok, err := q.Get(); !ok || err != nil || v != i || offset != uint64(i) {
+			t.Fatalf("Get = %v, %v, %v, %v, want %d", v, offset, ok, err, i)
+		}
+	}
+	// 取出了0-5，只确认了0-3，4和5重启之后要再次取出
+	if err := q.Ack(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := lastSegment(t, dir)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 头部声明100字节的数据，实际只写了3字节
+	if _, err := f.Write([]byte{100, 0, 0, 0, 1, 2, 3, 4, 'a', 'b', 'c'}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	q, err = OpenDiskQueue[int](dir, GobCodec[int]{}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if after, err := os.Stat(path); err != nil || after.Size() != info.Size() {
+		t.Fatalf("torn record was not truncated: size %v, want %v (%v)", after.Size(), info.Size(), err)
+	}
+	if n := q.Len(); n != 6 {
+		t.Fatalf("Len = %d after reopen, want 6", n)
+	}
+	var got []int
+	for {
+		v, offset, ok, err := q.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		if offset != uint64(v) {
+			t.Fatalf("value %d has offset %d", v, offset)
+		}
+		got = append(got, v)
+	}
+	if want := []int{4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("values after reopen = %v, want %v", got, want)
+	}
+	// 新写入的记录接在截断的位置之后
+	if offset, err := q.Put(10); err != nil || offset != 10 {
+		t.Fatalf("Put after reopen = %d, %v, want 10", offset, err)
+	}
+	if v, _, ok, err := q.Get(); !ok || err != nil || v != 10 {
+		t.Fatalf("Get after reopen = %v, %v, %v", v, ok, err)
+	}
+}
+
+func TestDiskQueueJSONCodecMap(t *testing.T) {
+	type event struct {
+		Tags map[string]int
+	}
+	q, err := OpenDiskQueue[event](t.TempDir(), JSONCodec[event]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	want := event{Tags: map[string]int{"a": 1, "b": 2}}
+	if _, err := q.Put(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, ok, err := q.Get(); !ok || err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("Get = %v, %v, %v, want %v", got, ok, err, want)
+	}
+}
+
+// 从磁盘取出但是没有确认的记录，重启之后再次取出
+func TestSpillQueueAckAfterHandling(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := OpenDiskQueue[int](dir, GobCodec[int]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewSpillQueue(NewQueueOf[int](2, time.Microsecond), disk)
+	for i := 0; i < 5; i++ {
+		if err := q.Put(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		v, offset, ok, err := q.Get()
+		if !ok || err != nil || v != i {
+			t.Fatalf("Get = %v, %v, %v, want %d", v, ok, err, i)
+		}
+		if i < 2 {
+			if offset != SpillMemoryOffset {
+				t.Fatalf("memory item %d has offset %d", v, offset)
+			}
+			if err := q.Ack(offset); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// 2来自磁盘，模拟处理之前崩溃，不确认
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	disk, err = OpenDiskQueue[int](dir, GobCodec[int]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+	var got []int
+	for {
+		v, _, ok, err := disk.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, v)
+	}
+	if want := []int{2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("values after reopen = %v, want %v", got, want)
+	}
+}
+
+// 多个协程并发取出，内存中还有记录时不能先取出磁盘中更晚插入的记录，每个协程取到的记录是递增的
+func TestSpillQueueGetKeepsOrderUnderContention(t *testing.T) {
+	disk, err := OpenDiskQueue[int](t.TempDir(), GobCodec[int]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewSpillQueue(NewQueueOf[int](64, time.Microsecond), disk)
+	defer q.Close()
+	const total = 256 // 前64条在内存中，之后的写入磁盘
+	for i := 0; i < total; i++ {
+		if err := q.Put(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	results := make([][]int, 8)
+	for w := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, _, ok, err := q.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !ok {
+					return
+				}
+				results[w] = append(results[w], v)
+			}
+		}()
+	}
+	wg.Wait()
+	count := 0
+	for w, values := range results {
+		for i := 1; i < len(values); i++ {
+			if values[i] < values[i-1] {
+				t.Fatalf("consumer %d got %d after %d", w, values[i], values[i-1])
+			}
+		}
+		count += len(values)
+	}
+	if count != total {
+		t.Fatalf("got %d values, want %d", count, total)
+	}
+}
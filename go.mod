@@ -1,10 +1,15 @@
 module github.com/93Alliance/lodago
 
-go 1.14
+go 1.24
 
 require (
-	github.com/satori/go.uuid v1.2.0
-	github.com/json-iterator/go v1.1.9
+	github.com/json-iterator/go v1.1.12
 	github.com/mitchellh/mapstructure v1.2.2
 	github.com/robfig/cron/v3 v3.0.1
+	github.com/satori/go.uuid v1.2.0
+)
+
+require (
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 )
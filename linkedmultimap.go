@@ -0,0 +1,138 @@
+package lodago
+
+import (
+	"container/list"
+	"iter"
+)
+
+// 按照插入顺序排列key的multimap，key按照第一次插入的顺序排列，删除之后再插入排到最后，
+// 同一个key的values本来就按照插入顺序排列，遍历的结果是可以重现的。
+
+// LinkedMultimap key按照插入顺序排列的multimap
+type LinkedMultimap[K comparable, V any] struct {
+	m     *MultimapOf[K, V]
+	order *list.List          // key的插入顺序
+	elems map[K]*list.Element // key在order中的位置
+}
+
+var _ Multimapper[string, int] = (*LinkedMultimap[string, int])(nil)
+
+// NewLinkedMultimap 构建key按照插入顺序排列的multimap，equal为可选的value比较函数，同NewMultimapOf
+func NewLinkedMultimap[K comparable, V any](equal ...func(a, b V) bool) *LinkedMultimap[K, V] {
+	return &LinkedMultimap[K, V]{
+		m:     NewMultimapOf[K, V](equal...),
+		order: list.New(),
+		elems: make(map[K]*list.Element),
+	}
+}
+
+// At 取出key对应的values
+func (multimap *LinkedMultimap[K, V]) At(key K) ([]V, bool) {
+	return multimap.m.At(key)
+}
+
+// Insert 插入一条记录
+func (multimap *LinkedMultimap[K, V]) Insert(key K, value V) {
+	if _, found := multimap.elems[key]; !found {
+		multimap.elems[key] = multimap.order.PushBack(key)
+	}
+	multimap.m.Insert(key, value)
+}
+
+// InsertValues 插入多条记录
+func (multimap *LinkedMultimap[K, V]) InsertValues(key K, values []V) {
+	for _, v := range values {
+		multimap.Insert(key, v)
+	}
+}
+
+// Remove 移除一条记录
+func (multimap *LinkedMultimap[K, V]) Remove(key K, value V) {
+	multimap.m.Remove(key, value)
+	if multimap.m.Count(key) == 0 {
+		multimap.removeKey(key)
+	}
+}
+
+// RemoveAll 删除关于key的所有键值对
+func (multimap *LinkedMultimap[K, V]) RemoveAll(key K) {
+	multimap.m.RemoveAll(key)
+	multimap.removeKey(key)
+}
+
+// Size 获取map当前键值对的数量
+func (multimap *LinkedMultimap[K, V]) Size() int {
+	return multimap.m.Size()
+}
+
+// IsEmpty 判断容器内是否为空
+func (multimap *LinkedMultimap[K, V]) IsEmpty() bool {
+	return multimap.m.IsEmpty()
+}
+
+// Count 获取key对应的value数量
+func (multimap *LinkedMultimap[K, V]) Count(key K) int {
+	return multimap.m.Count(key)
+}
+
+// Keys 获取所有的key，按照插入顺序排列
+func (multimap *LinkedMultimap[K, V]) Keys() []K {
+	keys := make([]K, 0, multimap.order.Len())
+	for e := multimap.order.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(K))
+	}
+	return keys
+}
+
+// removeKey 从插入顺序中删除key
+func (multimap *LinkedMultimap[K, V]) removeKey(key K) {
+	if e, found := multimap.elems[key]; found {
+		multimap.order.Remove(e)
+		delete(multimap.elems, key)
+	}
+}
+
+// Values 获取所有的value，key按照插入顺序排列
+func (multimap *LinkedMultimap[K, V]) Values() []V {
+	return multimapValues[K, V](multimap)
+}
+
+// Entries 获取所有的键值对，key按照插入顺序排列
+func (multimap *LinkedMultimap[K, V]) Entries() []MultimapEntry[K, V] {
+	return multimapEntries[K, V](multimap)
+}
+
+// ForEach 遍历所有的键值对，key按照插入顺序排列，fn返回false时停止，fn中不能修改这个multimap
+func (multimap *LinkedMultimap[K, V]) ForEach(fn func(key K, value V) bool) {
+	multimapForEach[K, V](multimap, fn)
+}
+
+// All 返回遍历所有键值对的迭代器，顺序同ForEach，循环中不能修改这个multimap
+func (multimap *LinkedMultimap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		multimap.ForEach(yield)
+	}
+}
+
+// ToMap 转换成map[K][]V，返回的是副本
+func (multimap *LinkedMultimap[K, V]) ToMap() map[K][]V {
+	return multimapToMap[K, V](multimap)
+}
+
+// MarshalJSON 序列化成{"key": [values...]}，key按照插入顺序排列，反序列化时按照文档中的顺序插入，实现json.Marshaler
+func (multimap *LinkedMultimap[K, V]) MarshalJSON() ([]byte, error) {
+	return marshalMultimap[K, V](multimap)
+}
+
+// UnmarshalJSON 从{"key": [values...]}反序列化，替换原有的内容，实现json.Unmarshaler
+func (multimap *LinkedMultimap[K, V]) UnmarshalJSON(data []byte) error {
+	return unmarshalMultimap(data, func() {
+		var equal func(a, b V) bool
+		if multimap.m != nil {
+			equal = multimap.m.equal
+		}
+		multimap.m = NewMultimapOf[K, V](equal)
+		multimap.order = list.New()
+		multimap.elems = make(map[K]*list.Element)
+	}, multimap.InsertValues)
+}
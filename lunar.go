@@ -0,0 +1,226 @@
+package lodago
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 农历（阴历）与公历之间的离线转换，数据表覆盖农历1900年至2100年。
+
+// 农历年份的范围
+const (
+	LunarMinYear = 1900
+	LunarMaxYear = 2100
+)
+
+// lunarInfo 农历1900-2100年的数据，每一年用一个整数表示：
+//
+//	bit 0-3: 闰月的月份，0表示没有闰月
+//	bit 4-15: 1月至12月的大小，从高位到低位分别是1月到12月，1为大月30天，0为小月29天
+//	bit 16: 闰月的大小，1为大月30天，0为小月29天
+var lunarInfo = [...]uint32{
+	0x04bd8, 0x04ae0, 0x0a570, 0x054d5, 0x0d260, 0x0d950, 0x16554, 0x056a0, 0x09ad0, 0x055d2, // 1900-1909
+	0x04ae0, 0x0a5b6, 0x0a4d0, 0x0d250, 0x1d255, 0x0b540, 0x0d6a0, 0x0ada2, 0x095b0, 0x14977, // 1910-1919
+	0x04970, 0x0a4b0, 0x0b4b5, 0x06a50, 0x06d40, 0x1ab54, 0x02b60, 0x09570, 0x052f2, 0x04970, // 1920-1929
+	0x06566, 0x0d4a0, 0x0ea50, 0x16a95, 0x05ad0, 0x02b60, 0x186e3, 0x092e0, 0x1c8d7, 0x0c950, // 1930-1939
+	0x0d4a0, 0x1d8a6, 0x0b550, 0x056a0, 0x1a5b4, 0x025d0, 0x092d0, 0x0d2b2, 0x0a950, 0x0b557, // 1940-1949
+	0x06ca0, 0x0b550, 0x15355, 0x04da0, 0x0a5b0, 0x14573, 0x052b0, 0x0a9a8, 0x0e950, 0x06aa0, // 1950-1959
+	0x0aea6, 0x0ab50, 0x04b60, 0x0aae4, 0x0a570, 0x05260, 0x0f263, 0x0d950, 0x05b57, 0x056a0, // 1960-1969
+	0x096d0, 0x04dd5, 0x04ad0, 0x0a4d0, 0x0d4d4, 0x0d250, 0x0d558, 0x0b540, 0x0b6a0, 0x195a6, // 1970-1979
+	0x095b0, 0x049b0, 0x0a974, 0x0a4b0, 0x0b27a, 0x06a50, 0x06d40, 0x0af46, 0x0ab60, 0x09570, // 1980-1989
+	0x04af5, 0x04970, 0x064b0, 0x074a3, 0x0ea50, 0x06b58, 0x05ac0, 0x0ab60, 0x096d5, 0x092e0, // 1990-1999
+	0x0c960, 0x0d954, 0x0d4a0, 0x0da50, 0x07552, 0x056a0, 0x0abb7, 0x025d0, 0x092d0, 0x0cab5, // 2000-2009
+	0x0a950, 0x0b4a0, 0x0baa4, 0x0ad50, 0x055d9, 0x04ba0, 0x0a5b0, 0x15176, 0x052b0, 0x0a930, // 2010-2019
+	0x07954, 0x06aa0, 0x0ad50, 0x05b52, 0x04b60, 0x0a6e6, 0x0a4e0, 0x0d260, 0x0ea65, 0x0d530, // 2020-2029
+	0x05aa0, 0x076a3, 0x096d0, 0x04afb, 0x04ad0, 0x0a4d0, 0x1d0b6, 0x0d250, 0x0d520, 0x0dd45, // 2030-2039
+	0x0b5a0, 0x056d0, 0x055b2, 0x049b0, 0x0a577, 0x0a4b0, 0x0aa50, 0x1b255, 0x06d20, 0x0ada0, // 2040-2049
+	0x14b63, 0x09370, 0x049f8, 0x04970, 0x064b0, 0x168a6, 0x0ea50, 0x06b20, 0x1a6c4, 0x0aae0, // 2050-2059
+	0x092e0, 0x0d2e3, 0x0c960, 0x0d557, 0x0d4a0, 0x0da50, 0x05d55, 0x056a0, 0x0a6d0, 0x055d4, // 2060-2069
+	0x052d0, 0x0a9b8, 0x0a950, 0x0b4a0, 0x0b6a6, 0x0ad50, 0x055a0, 0x0aba4, 0x0a5b0, 0x052b0, // 2070-2079
+	0x0b273, 0x06930, 0x07337, 0x06aa0, 0x0ad50, 0x14b55, 0x04b60, 0x0a570, 0x054e4, 0x0d160, // 2080-2089
+	0x0e968, 0x0d520, 0x0daa0, 0x16aa6, 0x056d0, 0x04ae0, 0x0a9d4, 0x0a2d0, 0x0d150, 0x0f252, // 2090-2099
+	0x0d520, // 2100
+}
+
+// lunarBase 农历1900年正月初一对应的公历日期
+var lunarBase = time.Date(1900, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+// ErrLunarOutOfRange 日期超出农历数据表的范围
+var ErrLunarOutOfRange = errors.New("Lunar date is out of range")
+
+// LunarDate 农历日期
+type LunarDate struct {
+	Year   int  `json:"year"`
+	Month  int  `json:"month"`
+	Day    int  `json:"day"`
+	IsLeap bool `json:"isLeap"` // 是否为闰月
+}
+
+// String 序列化成字符串，闰月在月份前加上"闰"
+func (d LunarDate) String() string {
+	if d.IsLeap {
+		return fmt.Sprintf("%04d-闰%02d-%02d", d.Year, d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// LunarLeapMonth 获取农历年份的闰月月份，没有闰月返回0
+func LunarLeapMonth(year int) int {
+	if year < LunarMinYear || year > LunarMaxYear {
+		return 0
+	}
+	return int(lunarInfo[year-LunarMinYear] & 0xf)
+}
+
+// LunarMonthDays 获取农历月份的天数，月份不存在时返回0
+func LunarMonthDays(year, month int, leap bool) int {
+	if year < LunarMinYear || year > LunarMaxYear || month < 1 || month > 12 {
+		return 0
+	}
+	info := lunarInfo[year-LunarMinYear]
+	if leap {
+		if LunarLeapMonth(year) != month {
+			return 0
+		}
+		if info&0x10000 != 0 {
+			return 30
+		}
+		return 29
+	}
+	if info&(0x10000>>uint(month)) != 0 {
+		return 30
+	}
+	return 29
+}
+
+// LunarYearDays 获取农历年份的总天数
+func LunarYearDays(year int) int {
+	if year < LunarMinYear || year > LunarMaxYear {
+		return 0
+	}
+	days := 0
+	for month := 1; month <= 12; month++ {
+		days += LunarMonthDays(year, month, false)
+	}
+	if leap := LunarLeapMonth(year); leap != 0 {
+		days += LunarMonthDays(year, leap, true)
+	}
+	return days
+}
+
+// SolarToLunar 公历转农历，只使用t的年月日
+func SolarToLunar(t time.Time) (LunarDate, error) {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := int(date.Sub(lunarBase).Hours() / 24)
+	if offset < 0 {
+		return LunarDate{}, ErrLunarOutOfRange
+	}
+	year := LunarMinYear
+	for ; year <= LunarMaxYear; year++ {
+		days := LunarYearDays(year)
+		if offset < days {
+			break
+		}
+		offset -= days
+	}
+	if year > LunarMaxYear {
+		return LunarDate{}, ErrLunarOutOfRange
+	}
+	leap := LunarLeapMonth(year)
+	for month := 1; month <= 12; month++ {
+		days := LunarMonthDays(year, month, false)
+		if offset < days {
+			return LunarDate{year, month, offset + 1, false}, nil
+		}
+		offset -= days
+		if month == leap { // 闰月紧跟在同名的月份之后
+			days = LunarMonthDays(year, month, true)
+			if offset < days {
+				return LunarDate{year, month, offset + 1, true}, nil
+			}
+			offset -= days
+		}
+	}
+	return LunarDate{}, ErrLunarOutOfRange
+}
+
+// LunarToSolar 农历转公历，返回loc时区当天的零点
+func LunarToSolar(d LunarDate, loc *time.Location) (time.Time, error) {
+	days := LunarMonthDays(d.Year, d.Month, d.IsLeap)
+	if days == 0 || d.Day < 1 || d.Day > days {
+		return time.Time{}, fmt.Errorf("Lunar date %s is invalid", d)
+	}
+	offset := 0
+	for year := LunarMinYear; year < d.Year; year++ {
+		offset += LunarYearDays(year)
+	}
+	leap := LunarLeapMonth(d.Year)
+	for month := 1; month < d.Month; month++ {
+		offset += LunarMonthDays(d.Year, month, false)
+		if month == leap {
+			offset += LunarMonthDays(d.Year, month, true)
+		}
+	}
+	if d.IsLeap { // 闰月在同名的月份之后
+		offset += LunarMonthDays(d.Year, d.Month, false)
+	}
+	offset += d.Day - 1
+	solar := lunarBase.AddDate(0, 0, offset)
+	if loc == nil {
+		loc = time.Local
+	}
+	return time.Date(solar.Year(), solar.Month(), solar.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// lunarSchedule 每年农历的某月某日执行的时间表
+type lunarSchedule struct {
+	month, day   int
+	hour, minute int
+	leap         bool
+}
+
+// Next 下一次执行的时间，超出农历数据表范围时返回零值。
+// 闰月的时间表只在有这个闰月的年份执行，日期超过当月天数时（例如小月的三十）在当月最后一天执行。
+func (s lunarSchedule) Next(t time.Time) time.Time {
+	current, err := SolarToLunar(t)
+	year := current.Year - 1 // 公历年初可能还在农历的上一年
+	if err != nil {
+		year = LunarMinYear
+	}
+	for ; year <= LunarMaxYear; year++ {
+		days := LunarMonthDays(year, s.month, s.leap)
+		if days == 0 {
+			continue
+		}
+		day := s.day
+		if day > days {
+			day = days
+		}
+		date, err := LunarToSolar(LunarDate{year, s.month, day, s.leap}, t.Location())
+		if err != nil {
+			continue
+		}
+		next := date.Add(time.Duration(s.hour)*time.Hour + time.Duration(s.minute)*time.Minute)
+		if next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// 农历时间表，月份和日期需要在合法范围内
+func (c *CronTime) toLunarSchedule() (lunarSchedule, error) {
+	if !c.isNums(c.Month, c.Day, c.Hour, c.Minute) {
+		return lunarSchedule{}, errors.New("Time format is error")
+	}
+	month, _ := strconv.Atoi(c.Month)
+	day, _ := strconv.Atoi(c.Day)
+	hour, _ := strconv.Atoi(c.Hour)
+	minute, _ := strconv.Atoi(c.Minute)
+	if month < 1 || month > 12 || day < 1 || day > 30 || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return lunarSchedule{}, errors.New("Time format is error")
+	}
+	return lunarSchedule{month, day, hour, minute, c.Leap}, nil
+}
@@ -0,0 +1,113 @@
+package lodago
+
+import (
+	"testing"
+	"time"
+)
+
+func solarDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLunarConversion(t *testing.T) {
+	tests := []struct {
+		name  string
+		solar time.Time
+		lunar LunarDate
+	}{
+		{"first day of the table", solarDate(1900, time.January, 31), LunarDate{1900, 1, 1, false}},
+		{"spring festival 1901", solarDate(1901, time.February, 19), LunarDate{1901, 1, 1, false}},
+		{"spring festival 1985", solarDate(1985, time.February, 20), LunarDate{1985, 1, 1, false}},
+		{"spring festival 2000", solarDate(2000, time.February, 5), LunarDate{2000, 1, 1, false}},
+		{"spring festival 2020", solarDate(2020, time.January, 25), LunarDate{2020, 1, 1, false}},
+		{"spring festival 2024", solarDate(2024, time.February, 10), LunarDate{2024, 1, 1, false}},
+		{"spring festival 2025", solarDate(2025, time.January, 29), LunarDate{2025, 1, 1, false}},
+		{"spring festival 2100", solarDate(2100, time.February, 9), LunarDate{2100, 1, 1, false}},
+		{"new year's eve 2023", solarDate(2024, time.February, 9), LunarDate{2023, 12, 30, false}},
+		{"mid-autumn 2024", solarDate(2024, time.September, 17), LunarDate{2024, 8, 15, false}},
+		// 2023年闰二月：二月初一、闰二月初一、闰二月廿九、三月初一
+		{"2023 month 2", solarDate(2023, time.February, 20), LunarDate{2023, 2, 1, false}},
+		{"2023 leap month 2", solarDate(2023, time.March, 22), LunarDate{2023, 2, 1, true}},
+		{"2023 leap month 2 last day", solarDate(2023, time.April, 19), LunarDate{2023, 2, 29, true}},
+		{"2023 month 3", solarDate(2023, time.April, 20), LunarDate{2023, 3, 1, false}},
+		// 2033年闰十一月
+		{"2033 month 11", solarDate(2033, time.November, 22), LunarDate{2033, 11, 1, false}},
+		{"2033 leap month 11", solarDate(2033, time.December, 22), LunarDate{2033, 11, 1, true}},
+		{"2033 month 12", solarDate(2034, time.January, 20), LunarDate{2033, 12, 1, false}},
+		{"last day of the table", solarDate(2101, time.January, 28), LunarDate{2100, 12, 29, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lunar, err := SolarToLunar(tt.solar)
+			if err != nil || lunar != tt.lunar {
+				t.Fatalf("SolarToLunar(%s) = %s, %v, want %s", tt.solar.Format("2006-01-02"), lunar, err, tt.lunar)
+			}
+			solar, err := LunarToSolar(tt.lunar, time.UTC)
+			if err != nil || !solar.Equal(tt.solar) {
+				t.Fatalf("LunarToSolar(%s) = %v, %v, want %v", tt.lunar, solar, err, tt.solar)
+			}
+		})
+	}
+
+	if LunarLeapMonth(2023) != 2 || LunarLeapMonth(2033) != 11 || LunarLeapMonth(2024) != 0 {
+		t.Fatalf("leap months = %d, %d, %d", LunarLeapMonth(2023), LunarLeapMonth(2033), LunarLeapMonth(2024))
+	}
+	// 每一年的天数等于下一年春节和今年春节之间的天数
+	for year := LunarMinYear; year < LunarMaxYear; year++ {
+		start, _ := LunarToSolar(LunarDate{year, 1, 1, false}, time.UTC)
+		end, _ := LunarToSolar(LunarDate{year + 1, 1, 1, false}, time.UTC)
+		if days := int(end.Sub(start).Hours() / 24); days != LunarYearDays(year) {
+			t.Fatalf("year %d has %d days, table says %d", year, days, LunarYearDays(year))
+		}
+	}
+}
+
+func TestLunarOutOfRange(t *testing.T) {
+	for _, solar := range []time.Time{solarDate(1900, time.January, 30), solarDate(2101, time.January, 29), solarDate(1899, time.June, 1)} {
+		if lunar, err := SolarToLunar(solar); err != ErrLunarOutOfRange {
+			t.Errorf("SolarToLunar(%s) = %s, %v, want ErrLunarOutOfRange", solar.Format("2006-01-02"), lunar, err)
+		}
+	}
+	invalid := []LunarDate{
+		{1899, 12, 1, false},
+		{2101, 1, 1, false},
+		{2024, 2, 1, true},  // 2024年没有闰月
+		{2023, 3, 1, true},  // 2023年闰的是二月
+		{2023, 2, 30, true}, // 闰二月只有29天
+		{2024, 13, 1, false},
+		{2024, 1, 0, false},
+	}
+	for _, lunar := range invalid {
+		if solar, err := LunarToSolar(lunar, time.UTC); err == nil {
+			t.Errorf("LunarToSolar(%s) = %v, want error", lunar, solar)
+		}
+	}
+}
+
+func TestLunarScheduleNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		schedule lunarSchedule
+		from     time.Time
+		want     time.Time
+	}{
+		{"mid-autumn", lunarSchedule{month: 8, day: 15, hour: 10}, at(2024, time.January, 1, 0), at(2024, time.September, 17, 10)},
+		{"same day after the hour", lunarSchedule{month: 8, day: 15, hour: 10}, at(2024, time.September, 17, 10), at(2025, time.October, 6, 10)},
+		{"solar year starts before spring festival", lunarSchedule{month: 12, day: 1, hour: 8}, at(2024, time.January, 5, 0), at(2024, time.January, 11, 8)},
+		{"leap month", lunarSchedule{month: 2, day: 1, hour: 9, leap: true}, at(2020, time.January, 1, 0), at(2023, time.March, 22, 9)},
+		{"leap month skips years without it", lunarSchedule{month: 2, day: 1, hour: 9, leap: true}, at(2023, time.March, 22, 9), at(2042, time.March, 22, 9)},
+		{"leap month day 30 runs on the last day", lunarSchedule{month: 2, day: 30, leap: true}, at(2023, time.January, 1, 0), at(2023, time.April, 19, 0)},
+		{"ordinary month 2 in a leap year", lunarSchedule{month: 2, day: 1, hour: 9}, at(2023, time.February, 21, 0), at(2024, time.March, 10, 9)},
+		{"after the table", lunarSchedule{month: 12, day: 29}, at(2101, time.January, 28, 12), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Fatalf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
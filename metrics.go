@@ -0,0 +1,261 @@
+package lodago
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 不依赖Prometheus客户端库的简单指标收集，输出Prometheus的文本格式。
+
+// MetricType 指标类型
+type MetricType string
+
+// 支持的指标类型
+const (
+	CounterMetric   MetricType = "counter"   // 计数器
+	GaugeMetric     MetricType = "gauge"     // 仪表盘
+	HistogramMetric MetricType = "histogram" // 直方图
+)
+
+// DefaultDurationBuckets 默认的耗时直方图分桶，单位秒
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// MetricSample 指标的一个采样，计数器和仪表盘使用Value，直方图使用Buckets、Counts、Count和Sum
+type MetricSample struct {
+	Labels  map[string]string
+	Value   float64
+	Buckets []float64 // 直方图分桶的上界
+	Counts  []uint64  // 直方图每个分桶的累计数量
+	Count   uint64
+	Sum     float64
+}
+
+// Metric 指标，同名的所有采样
+type Metric struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Samples []MetricSample
+}
+
+// Collector 指标收集器
+type Collector interface {
+	Collect() []Metric
+}
+
+// CollectorFunc 函数形式的收集器
+type CollectorFunc func() []Metric
+
+// Collect 收集指标
+func (f CollectorFunc) Collect() []Metric {
+	return f()
+}
+
+// Counter 计数器，线程安全
+type Counter struct {
+	value uint64
+}
+
+// Inc 加一
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add 加上n
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value 当前值
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// Histogram 直方图，线程安全
+type Histogram struct {
+	buckets []float64
+	counts  []uint64 // 每个分桶自身的数量，输出时再累加
+	count   uint64
+	sumBits uint64 // float64的二进制表示，用于原子操作
+}
+
+// NewHistogram 创建直方图，buckets为分桶的上界，为空时使用DefaultDurationBuckets
+func NewHistogram(buckets ...float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	bs := append([]float64(nil), buckets...)
+	sort.Float64s(bs)
+	return &Histogram{
+		buckets: bs,
+		counts:  make([]uint64, len(bs)),
+	}
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	idx := sort.SearchFloat64s(h.buckets, v)
+	if idx < len(h.counts) {
+		atomic.AddUint64(&h.counts[idx], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, sum) {
+			return
+		}
+	}
+}
+
+// Sample 生成直方图的采样
+func (h *Histogram) Sample(labels map[string]string) MetricSample {
+	counts := make([]uint64, len(h.counts))
+	cumulative := uint64(0)
+	for i := range h.counts {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		counts[i] = cumulative
+	}
+	return MetricSample{
+		Labels:  labels,
+		Buckets: h.buckets,
+		Counts:  counts,
+		Count:   atomic.LoadUint64(&h.count),
+		Sum:     math.Float64frombits(atomic.LoadUint64(&h.sumBits)),
+	}
+}
+
+// WithLabels 给收集器的所有采样加上固定的标签，例如区分多个队列
+func WithLabels(collector Collector, labels map[string]string) Collector {
+	return CollectorFunc(func() []Metric {
+		metrics := collector.Collect()
+		for i := range metrics {
+			for j := range metrics[i].Samples {
+				sample := &metrics[i].Samples[j]
+				merged := make(map[string]string, len(labels)+len(sample.Labels))
+				for k, v := range labels {
+					merged[k] = v
+				}
+				for k, v := range sample.Labels {
+					merged[k] = v
+				}
+				sample.Labels = merged
+			}
+		}
+		return metrics
+	})
+}
+
+// WriteMetrics 按照Prometheus的文本格式输出所有收集器的指标，同名指标会合并输出
+func WriteMetrics(w io.Writer, collectors ...Collector) error {
+	var names []string
+	families := make(map[string]*Metric)
+	for _, collector := range collectors {
+		for _, metric := range collector.Collect() {
+			family, found := families[metric.Name]
+			if !found {
+				m := metric
+				m.Samples = append([]MetricSample(nil), metric.Samples...)
+				families[metric.Name] = &m
+				names = append(names, metric.Name)
+				continue
+			}
+			family.Samples = append(family.Samples, metric.Samples...)
+		}
+	}
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		writeMetric(bw, families[name])
+	}
+	return bw.Flush()
+}
+
+// MetricsHandler 输出指标的http处理器
+func MetricsHandler(collectors ...Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, collectors...)
+	})
+}
+
+// 输出一个指标
+func writeMetric(w *bufio.Writer, metric *Metric) {
+	if metric.Help != "" {
+		w.WriteString("# HELP " + metric.Name + " " + escapeHelp(metric.Help) + "\n")
+	}
+	w.WriteString("# TYPE " + metric.Name + " " + string(metric.Type) + "\n")
+	for _, sample := range metric.Samples {
+		if metric.Type != HistogramMetric {
+			writeSample(w, metric.Name, sample.Labels, "", "", sample.Value)
+			continue
+		}
+		for i, bound := range sample.Buckets {
+			writeSample(w, metric.Name+"_bucket", sample.Labels, "le", formatFloat(bound), float64(sample.Counts[i]))
+		}
+		writeSample(w, metric.Name+"_bucket", sample.Labels, "le", "+Inf", float64(sample.Count))
+		writeSample(w, metric.Name+"_sum", sample.Labels, "", "", sample.Sum)
+		writeSample(w, metric.Name+"_count", sample.Labels, "", "", float64(sample.Count))
+	}
+}
+
+// 输出一行采样，extraName不为空时追加一个标签（直方图的le）
+func writeSample(w *bufio.Writer, name string, labels map[string]string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(k + "=\"" + escapeLabel(labels[k]) + "\"")
+		}
+		if extraName != "" {
+			if len(keys) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName + "=\"" + extraValue + "\"")
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// 浮点数格式化
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer("\\", `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+)
+
+// 转义帮助信息
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+// 转义标签值
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,159 @@
+package lodago
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 多生产者单消费者（MPSC）的环形队列，生产者通过CAS预定位置，每个位置带有序号，
+// 消费者只有一个，取出时不需要CAS。只能有一个协程取出，否则结果是未定义的。
+
+// MPSCQueue 元素类型为interface{}的多生产者单消费者队列
+type MPSCQueue = MPSCQueueOf[interface{}]
+
+// mpsc的缓存，seq等于位置时可以写入，等于位置+1时可以取出
+type mpscCache[T any] struct {
+	seq   uint64
+	_     [cacheLinePad - 8]byte
+	value T
+}
+
+// MPSCQueueOf 元素类型为T的多生产者单消费者队列
+type MPSCQueueOf[T any] struct {
+	_         [cacheLinePad]byte
+	putPos    uint64 // 生产者竞争写入
+	_         [cacheLinePad - 8]byte
+	getPos    uint64 // 消费者写入
+	_         [cacheLinePad - 8]byte
+	capacity  uint64
+	capMod    uint64
+	cache     []mpscCache[T]
+	sleepTime time.Duration
+	_         [cacheLinePad]byte
+}
+
+var _ Queuer[int] = (*MPSCQueueOf[int])(nil)
+
+// NewMPSCQueue 创建一个多生产者单消费者队列，容量向上取整为2的次方
+func NewMPSCQueue(capacity uint64, sleepTime time.Duration) *MPSCQueue {
+	return NewMPSCQueueOf[interface{}](capacity, sleepTime)
+}
+
+// NewMPSCQueueOf 创建一个元素类型为T的多生产者单消费者队列
+func NewMPSCQueueOf[T any](capacity uint64, sleepTime time.Duration) *MPSCQueueOf[T] {
+	q := new(MPSCQueueOf[T])
+	q.capacity = minQuantity(capacity)
+	q.capMod = q.capacity - 1
+	q.sleepTime = sleepTime
+	q.cache = make([]mpscCache[T], q.capacity)
+	for i := range q.cache {
+		q.cache[i].seq = uint64(i)
+	}
+	return q
+}
+
+// ToString 序列化成字符串
+func (q *MPSCQueueOf[T]) ToString() string {
+	getPos := atomic.LoadUint64(&q.getPos)
+	putPos := atomic.LoadUint64(&q.putPos)
+	return fmt.Sprintf("MPSCQueue{capacity: %v, capMod: %v, putPos: %v, getPos: %v}",
+		q.capacity, q.capMod, putPos, getPos)
+}
+
+// GetCapacity 获取容量
+func (q *MPSCQueueOf[T]) GetCapacity() uint64 {
+	return q.capacity
+}
+
+// GetQuantity 获取当前队列剩余多少条记录
+func (q *MPSCQueueOf[T]) GetQuantity() uint64 {
+	getPos := atomic.LoadUint64(&q.getPos)
+	putPos := atomic.LoadUint64(&q.putPos)
+	if putPos >= getPos {
+		return putPos - getPos
+	}
+	return 0
+}
+
+// Put 向队列插入数据，可以在多个协程并发调用，返回是否成功，剩余数量。
+func (q *MPSCQueueOf[T]) Put(value T) (bool, uint64) {
+	n, posCnt := q.Puts([]T{value})
+	return n == 1, posCnt
+}
+
+// Get 从队列中获取记录，只能在消费者协程调用，返回取出的值，是否成功，剩余数量。
+func (q *MPSCQueueOf[T]) Get() (T, bool, uint64) {
+	var values [1]T
+	n, posCnt := q.Gets(values[:])
+	return values[0], n == 1, posCnt
+}
+
+// Puts 向队列插入多条数据，可以在多个协程并发调用，返回添加的记录数量，剩余数量。
+func (q *MPSCQueueOf[T]) Puts(values []T) (int, uint64) {
+	var bo qbackoff
+	var putPos, posCnt, putCnt uint64
+	for retry := 0; ; retry++ {
+		getPos := atomic.LoadUint64(&q.getPos)
+		putPos = atomic.LoadUint64(&q.putPos)
+		posCnt = 0
+		if putPos > getPos {
+			posCnt = putPos - getPos
+		}
+		if posCnt >= q.capacity { // 队列已满
+			time.Sleep(q.sleepTime)
+			return 0, posCnt
+		}
+		putCnt = q.capacity - posCnt
+		if size := uint64(len(values)); putCnt > size {
+			putCnt = size
+		}
+		if atomic.CompareAndSwapUint64(&q.putPos, putPos, putPos+putCnt) {
+			break
+		}
+		if retry >= qCASRetries {
+			return 0, posCnt
+		}
+		bo.pause()
+	}
+	for v := uint64(0); v < putCnt; v++ {
+		pos := putPos + v
+		cache := &q.cache[pos&q.capMod]
+		// getPos可能是旧值，等待消费者归还这个位置
+		for bo = 0; atomic.LoadUint64(&cache.seq) != pos; {
+			bo.pause()
+		}
+		cache.value = values[v]
+		atomic.StoreUint64(&cache.seq, pos+1) // 发布写入的数据
+	}
+	return int(putCnt), posCnt + putCnt
+}
+
+// Gets 获取多条记录，只能在消费者协程调用，返回获取的记录数量，剩余数量。
+func (q *MPSCQueueOf[T]) Gets(values []T) (int, uint64) {
+	var zero T
+	getPos := q.getPos // 只有消费者写getPos，不需要原子读取
+	putPos := atomic.LoadUint64(&q.putPos)
+	posCnt := putPos - getPos
+	if posCnt == 0 {
+		time.Sleep(q.sleepTime)
+		return 0, 0
+	}
+	getCnt := uint64(len(values))
+	if getCnt > posCnt {
+		getCnt = posCnt
+	}
+	for v := uint64(0); v < getCnt; v++ {
+		pos := getPos + v
+		cache := &q.cache[pos&q.capMod]
+		// 位置已经被生产者预定，等待写入完成
+		for bo := qbackoff(0); atomic.LoadUint64(&cache.seq) != pos+1; {
+			bo.pause()
+		}
+		values[v] = cache.value
+		cache.value = zero                             // 释放引用
+		atomic.StoreUint64(&cache.seq, pos+q.capacity) // 归还位置给下一轮的生产者
+	}
+	atomic.StoreUint64(&q.getPos, getPos+getCnt)
+	return int(getCnt), posCnt - getCnt
+}
@@ -0,0 +1,13 @@
+package lodago
+
+import (
+	"testing"
+	"time"
+)
+
+// 用go test -race运行
+func TestMPSCQueueStress(t *testing.T) {
+	for _, producers := range []int{1, 4, 16} {
+		stressQueuer(t, NewMPSCQueueOf[int](64, time.Microsecond), producers, stressCount())
+	}
+}
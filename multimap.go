@@ -1,56 +1,182 @@
 package lodago
 
-// Multimap 允许key值重复
-type Multimap struct {
-	m    map[interface{}][]interface{}
-	size int
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"slices"
+
+	json "github.com/json-iterator/go"
+)
+
+// Multimapper multimap的通用接口，MultimapOf、SetMultimap、SortedMultimap、LinkedMultimap都实现了这个接口
+type Multimapper[K comparable, V any] interface {
+	At(key K) ([]V, bool)
+	Insert(key K, value V)
+	InsertValues(key K, values []V)
+	Remove(key K, value V)
+	RemoveAll(key K)
+	Size() int
+	IsEmpty() bool
+	Count(key K) int
+	Keys() []K
+	Values() []V
+	Entries() []MultimapEntry[K, V]
+	ForEach(fn func(key K, value V) bool)
+	All() iter.Seq2[K, V]
+	ToMap() map[K][]V
+}
+
+// Multimap 允许key值重复，key和value都是interface{}，等同于MultimapOf[interface{}, interface{}]
+type Multimap = MultimapOf[interface{}, interface{}]
+
+// MultimapOf 允许key值重复，key的类型为K，value的类型为V
+type MultimapOf[K comparable, V any] struct {
+	m     map[K][]V
+	size  int
+	equal func(a, b V) bool // 移除时比较value
+}
+
+var _ Multimapper[string, int] = (*MultimapOf[string, int])(nil)
+
+// MultimapEntry multimap中的一个键值对
+type MultimapEntry[K comparable, V any] struct {
+	Key   K
+	Value V
 }
 
 // NewMultimap 构建multimap
 func NewMultimap() *Multimap {
-	return &Multimap{
-		m:    make(map[interface{}][]interface{}),
-		size: 0,
+	return NewMultimapOf[interface{}, interface{}]()
+}
+
+// NewMultimapOf 构建key的类型为K，value的类型为V的multimap，equal为可选的value比较函数，
+// 默认可以比较的值使用==，不能比较的值（例如切片、map）使用reflect.DeepEqual，比较方式在构建时根据V的类型选定
+func NewMultimapOf[K comparable, V any](equal ...func(a, b V) bool) *MultimapOf[K, V] {
+	multimap := &MultimapOf[K, V]{
+		m:     make(map[K][]V),
+		size:  0,
+		equal: newDefaultEqual[V](),
 	}
+	if len(equal) > 0 && equal[0] != nil {
+		multimap.equal = equal[0]
+	}
+	return multimap
 }
 
 // At 取出key对应的values
-func (multimap *Multimap) At(key interface{}) ([]interface{}, bool) {
+func (multimap *MultimapOf[K, V]) At(key K) ([]V, bool) {
 	values, found := multimap.m[key]
 	return values, found
 }
 
 // Insert 插入一条记录
-func (multimap *Multimap) Insert(key interface{}, value interface{}) {
+func (multimap *MultimapOf[K, V]) Insert(key K, value V) {
 	multimap.m[key] = append(multimap.m[key], value)
 	multimap.size++
 }
 
 // InsertValues 插入多条记录
-func (multimap *Multimap) InsertValues(key interface{}, values []interface{}) {
+func (multimap *MultimapOf[K, V]) InsertValues(key K, values []V) {
 	for _, v := range values {
 		multimap.Insert(key, v)
 	}
 }
 
-// Remove 移除一条记录
-func (multimap *Multimap) Remove(key interface{}, value interface{}) {
+// Remove 移除key下所有等于value的记录
+func (multimap *MultimapOf[K, V]) Remove(key K, value V) {
+	multimap.RemoveIf(key, func(v V) bool {
+		return multimap.equal(v, value)
+	})
+}
+
+// RemoveIf 移除key下所有满足pred的记录，返回移除的数量。
+// 有记录被移除时把剩余的values复制到新的切片，之前通过At取得的切片不会被修改
+func (multimap *MultimapOf[K, V]) RemoveIf(key K, pred func(value V) bool) int {
 	values, found := multimap.m[key]
-	if found {
-		for idx, v := range values {
-			if v == value {
-				multimap.m[key] = append(values[:idx], values[idx+1:]...)
-				multimap.size--
+	if !found {
+		return 0
+	}
+	var kept []V
+	removed := 0
+	for idx, v := range values {
+		if !pred(v) {
+			if removed > 0 {
+				kept = append(kept, v)
 			}
+			continue
 		}
+		if removed == 0 { // 第一次移除时才复制之前保留的value
+			kept = make([]V, idx, len(values)-1)
+			copy(kept, values[:idx])
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0
 	}
-	if len(multimap.m[key]) == 0 {
+	multimap.size -= removed
+	if len(kept) == 0 {
 		delete(multimap.m, key)
+	} else {
+		multimap.m[key] = kept
+	}
+	return removed
+}
+
+// RemoveFirst 移除key下第一条等于value的记录，没有时返回false，和RemoveIf一样不会修改At返回的切片
+func (multimap *MultimapOf[K, V]) RemoveFirst(key K, value V) bool {
+	values := multimap.m[key]
+	for idx, v := range values {
+		if multimap.equal(v, value) {
+			values = slices.Concat(values[:idx], values[idx+1:])
+			multimap.size--
+			if len(values) == 0 {
+				delete(multimap.m, key)
+			} else {
+				multimap.m[key] = values
+			}
+			return true
+		}
 	}
+	return false
+}
+
+// RemoveValueEverywhere 移除所有key下等于value的记录，返回移除的数量
+func (multimap *MultimapOf[K, V]) RemoveValueEverywhere(value V) int {
+	removed := 0
+	for key := range multimap.m {
+		removed += multimap.RemoveIf(key, func(v V) bool {
+			return multimap.equal(v, value)
+		})
+	}
+	return removed
+}
+
+// Retain 只保留满足pred的键值对，返回移除的数量
+func (multimap *MultimapOf[K, V]) Retain(pred func(key K, value V) bool) int {
+	removed := 0
+	for key := range multimap.m {
+		removed += multimap.RemoveIf(key, func(v V) bool {
+			return !pred(key, v)
+		})
+	}
+	return removed
+}
+
+// ReplaceValues 把key的values替换成values，values为空时删除key，返回原来的values
+func (multimap *MultimapOf[K, V]) ReplaceValues(key K, values []V) []V {
+	old := multimap.m[key]
+	multimap.RemoveAll(key)
+	multimap.InsertValues(key, values)
+	return old
 }
 
 // RemoveAll 删除关于key的所有键值对
-func (multimap *Multimap) RemoveAll(key interface{}) {
+func (multimap *MultimapOf[K, V]) RemoveAll(key K) {
 	values, found := multimap.m[key]
 	if found {
 		multimap.size -= len(values)
@@ -59,20 +185,314 @@ func (multimap *Multimap) RemoveAll(key interface{}) {
 }
 
 // Size 获取map当前键值对的数量
-func (multimap *Multimap) Size() int {
+func (multimap *MultimapOf[K, V]) Size() int {
 	return multimap.size
 }
 
 // IsEmpty 判断容器内是否为空
-func (multimap *Multimap) IsEmpty() bool {
+func (multimap *MultimapOf[K, V]) IsEmpty() bool {
 	return multimap.size == 0
 }
 
 // Count 获取key对应的value数量
-func (multimap *Multimap) Count(key interface{}) int {
+func (multimap *MultimapOf[K, V]) Count(key K) int {
 	values, found := multimap.m[key]
 	if found {
 		return len(values)
 	}
 	return 0
 }
+
+// Keys 获取所有的key，顺序不固定
+func (multimap *MultimapOf[K, V]) Keys() []K {
+	keys := make([]K, 0, len(multimap.m))
+	for key := range multimap.m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// NewMultimapFromMap 用map[K][]V构建multimap，复制values，没有value的key会被忽略，equal同NewMultimapOf
+func NewMultimapFromMap[K comparable, V any](m map[K][]V, equal ...func(a, b V) bool) *MultimapOf[K, V] {
+	multimap := NewMultimapOf[K, V](equal...)
+	for key, values := range m {
+		multimap.InsertValues(key, values)
+	}
+	return multimap
+}
+
+// Values 获取所有的value，不同key之间的顺序不固定，同一个key的values按照插入顺序排列
+func (multimap *MultimapOf[K, V]) Values() []V {
+	values := make([]V, 0, multimap.size)
+	for _, vs := range multimap.m {
+		values = append(values, vs...)
+	}
+	return values
+}
+
+// Entries 获取所有的键值对，顺序同Values
+func (multimap *MultimapOf[K, V]) Entries() []MultimapEntry[K, V] {
+	entries := make([]MultimapEntry[K, V], 0, multimap.size)
+	for key, values := range multimap.m {
+		for _, v := range values {
+			entries = append(entries, MultimapEntry[K, V]{Key: key, Value: v})
+		}
+	}
+	return entries
+}
+
+// ForEach 遍历所有的键值对，fn返回false时停止，fn中不能修改这个multimap
+func (multimap *MultimapOf[K, V]) ForEach(fn func(key K, value V) bool) {
+	for key, values := range multimap.m {
+		for _, v := range values {
+			if !fn(key, v) {
+				return
+			}
+		}
+	}
+}
+
+// All 返回遍历所有键值对的迭代器，循环中不能修改这个multimap
+//
+//	for key, value := range m.All() {
+//		...
+//	}
+func (multimap *MultimapOf[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		multimap.ForEach(yield)
+	}
+}
+
+// ToMap 转换成map[K][]V，返回的是副本
+func (multimap *MultimapOf[K, V]) ToMap() map[K][]V {
+	m := make(map[K][]V, len(multimap.m))
+	for key, values := range multimap.m {
+		m[key] = append([]V(nil), values...)
+	}
+	return m
+}
+
+// MarshalJSON 序列化成{"key": [values...]}，实现json.Marshaler，key的顺序不固定。
+// key需要是字符串、数字、布尔值或者实现encoding.TextMarshaler，key的类型是interface{}时用fmt.Sprint转换成字符串
+func (multimap *MultimapOf[K, V]) MarshalJSON() ([]byte, error) {
+	return marshalMultimap[K, V](multimap)
+}
+
+// UnmarshalJSON 从{"key": [values...]}反序列化，替换原有的内容，实现json.Unmarshaler。
+// key的类型是interface{}时反序列化之后的key是字符串
+func (multimap *MultimapOf[K, V]) UnmarshalJSON(data []byte) error {
+	return unmarshalMultimap(data, func() {
+		multimap.m = make(map[K][]V)
+		multimap.size = 0
+		if multimap.equal == nil {
+			multimap.equal = newDefaultEqual[V]()
+		}
+	}, multimap.InsertValues)
+}
+
+// multimapReader 按照Keys的顺序遍历multimap需要的方法
+type multimapReader[K comparable, V any] interface {
+	At(key K) ([]V, bool)
+	Keys() []K
+	Size() int
+}
+
+// multimapValues 按照Keys的顺序获取所有的value
+func multimapValues[K comparable, V any](m multimapReader[K, V]) []V {
+	values := make([]V, 0, m.Size())
+	for _, key := range m.Keys() {
+		vs, _ := m.At(key)
+		values = append(values, vs...)
+	}
+	return values
+}
+
+// multimapEntries 按照Keys的顺序获取所有的键值对
+func multimapEntries[K comparable, V any](m multimapReader[K, V]) []MultimapEntry[K, V] {
+	entries := make([]MultimapEntry[K, V], 0, m.Size())
+	multimapForEach(m, func(key K, value V) bool {
+		entries = append(entries, MultimapEntry[K, V]{Key: key, Value: value})
+		return true
+	})
+	return entries
+}
+
+// multimapForEach 按照Keys的顺序遍历所有的键值对，fn返回false时停止
+func multimapForEach[K comparable, V any](m multimapReader[K, V], fn func(key K, value V) bool) {
+	for _, key := range m.Keys() {
+		values, _ := m.At(key)
+		for _, v := range values {
+			if !fn(key, v) {
+				return
+			}
+		}
+	}
+}
+
+// multimapToMap 转换成map[K][]V，values是副本
+func multimapToMap[K comparable, V any](m multimapReader[K, V]) map[K][]V {
+	keys := m.Keys()
+	result := make(map[K][]V, len(keys))
+	for _, key := range keys {
+		values, _ := m.At(key)
+		result[key] = append([]V(nil), values...)
+	}
+	return result
+}
+
+// marshalMultimap 按照Keys的顺序序列化成{"key": [values...]}，有序的multimap输出的key也是有序的
+func marshalMultimap[K comparable, V any](m multimapReader[K, V]) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.Keys() {
+		name, err := multimapKeyString(key)
+		if err != nil {
+			return nil, err
+		}
+		nameJSON, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		values, _ := m.At(key)
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(nameJSON)
+		buf.WriteByte(':')
+		buf.Write(valuesJSON)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalMultimap 解析{"key": [values...]}，全部解析成功之后调用reset清空原有的内容，
+// 再按照文档中key的顺序调用insert，解析失败时原有的内容不变
+func unmarshalMultimap[K comparable, V any](data []byte, reset func(), insert func(key K, values []V)) error {
+	type entry struct {
+		key    K
+		values []V
+	}
+	var entries []entry
+	var keyErr error
+	iter := json.ConfigDefault.BorrowIterator(data)
+	defer json.ConfigDefault.ReturnIterator(iter)
+	iter.ReadMapCB(func(it *json.Iterator, name string) bool {
+		var values []V
+		it.ReadVal(&values)
+		if it.Error != nil {
+			return false
+		}
+		key, err := multimapParseKey[K](name)
+		if err != nil {
+			keyErr = err
+			return false
+		}
+		entries = append(entries, entry{key, values})
+		return true
+	})
+	if keyErr != nil {
+		return keyErr
+	}
+	if iter.Error != nil && iter.Error != io.EOF {
+		return iter.Error
+	}
+	reset()
+	for _, e := range entries {
+		insert(e.key, e.values)
+	}
+	return nil
+}
+
+// multimapKeyString 把key转换成json对象的字段名
+func multimapKeyString[K comparable](key K) (string, error) {
+	switch k := interface{}(key).(type) {
+	case string:
+		return k, nil
+	case encoding.TextMarshaler:
+		text, err := k.MarshalText()
+		return string(text), err
+	}
+	if rv := reflect.ValueOf(key); rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	return fmt.Sprint(key), nil
+}
+
+// multimapParseKey 把json对象的字段名转换成key，数字和布尔值按照json解析
+func multimapParseKey[K comparable](name string) (K, error) {
+	var key K
+	if u, ok := interface{}(&key).(encoding.TextUnmarshaler); ok {
+		return key, u.UnmarshalText([]byte(name))
+	}
+	rv := reflect.ValueOf(&key).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(name)
+		return key, nil
+	case reflect.Interface:
+		if !reflect.TypeOf(name).AssignableTo(rv.Type()) {
+			return key, fmt.Errorf("Multimap key %q is not assignable to %v", name, rv.Type())
+		}
+		rv.Set(reflect.ValueOf(name))
+		return key, nil
+	}
+	if err := json.Unmarshal([]byte(name), &key); err != nil {
+		return key, fmt.Errorf("Multimap key %q: %w", name, err)
+	}
+	return key, nil
+}
+
+// newDefaultEqual 根据V的类型选择默认的比较函数，只在构建时判断一次：
+// V的所有值都能用==比较时直接使用==，V是切片、map等不能比较的类型时使用reflect.DeepEqual，
+// V是接口或者包含接口时要看运行时的类型，使用dynamicEqual
+func newDefaultEqual[V any]() func(a, b V) bool {
+	t := reflect.TypeFor[V]()
+	switch {
+	case strictlyComparable(t):
+		return func(a, b V) bool {
+			return interface{}(a) == interface{}(b)
+		}
+	case !t.Comparable():
+		return func(a, b V) bool {
+			return reflect.DeepEqual(a, b)
+		}
+	}
+	return dynamicEqual[V]
+}
+
+// strictlyComparable 类型的所有值都能用==比较，不包含接口，==不会panic
+func strictlyComparable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return false
+	case reflect.Array:
+		return strictlyComparable(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !strictlyComparable(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return t.Comparable()
+}
+
+// dynamicEqual 根据运行时的类型比较，值可以比较时使用==，否则使用reflect.DeepEqual，不会因为不能比较而panic
+func dynamicEqual[V any](a, b V) bool {
+	x, y := interface{}(a), interface{}(b)
+	if x == nil || y == nil {
+		return x == y
+	}
+	if reflect.TypeOf(x) != reflect.TypeOf(y) {
+		return false
+	}
+	if reflect.ValueOf(x).Comparable() {
+		return x == y
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,397 @@
+package lodago
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSortedMultimapOrderedIteration(t *testing.T) {
+	m := NewSortedMultimap[int, string]()
+	m.InsertValues(30, []string{"c1", "c2"})
+	m.Insert(10, "a")
+	m.Insert(20, "b")
+	if got, want := m.Values(), []string{"a", "b", "c1", "c2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values = %v, want %v", got, want)
+	}
+	var keys []int
+	for key, value := range m.All() {
+		keys = append(keys, key)
+		if value == "b" {
+			break
+		}
+	}
+	if want := []int{10, 20}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("All with break = %v, want %v", keys, want)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"10":["a"],"20":["b"],"30":["c1","c2"]}`; string(data) != want {
+		t.Fatalf("MarshalJSON = %s, want %s", data, want)
+	}
+	decoded := NewSortedMultimap[int, string]()
+	if err := json.Unmarshal([]byte(`{"5":["x"],"1":["y","z"]}`), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Keys(), []int{1, 5}; !reflect.DeepEqual(got, want) || decoded.Size() != 3 {
+		t.Fatalf("decoded keys = %v, size %d", got, decoded.Size())
+	}
+}
+
+func TestLinkedMultimapOrderedIteration(t *testing.T) {
+	m := NewLinkedMultimap[string, int]()
+	m.Insert("b", 1)
+	m.Insert("a", 2)
+	m.Insert("b", 3)
+	var entries []MultimapEntry[string, int]
+	m.ForEach(func(key string, value int) bool {
+		entries = append(entries, MultimapEntry[string, int]{key, value})
+		return true
+	})
+	want := []MultimapEntry[string, int]{{"b", 1}, {"b", 3}, {"a", 2}}
+	if !reflect.DeepEqual(entries, want) || !reflect.DeepEqual(m.Entries(), want) {
+		t.Fatalf("ForEach = %v, Entries = %v, want %v", entries, m.Entries(), want)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":[1,3],"a":[2]}`; string(data) != want {
+		t.Fatalf("MarshalJSON = %s, want %s", data, want)
+	}
+	// 反序列化按照文档中的顺序插入
+	decoded := NewLinkedMultimap[string, int]()
+	decoded.Insert("old", 0)
+	if err := json.Unmarshal([]byte(`{"z":[1],"y":[2,3],"x":[]}`), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Keys(), []string{"z", "y"}; !reflect.DeepEqual(got, want) || decoded.Size() != 3 {
+		t.Fatalf("decoded keys = %v, size %d", got, decoded.Size())
+	}
+}
+
+func TestMultimapJSON(t *testing.T) {
+	m := NewMultimap()
+	m.InsertValues("a", []interface{}{1.0, "x"})
+	m.Insert(2, "y")
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewMultimap()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	// interface{}类型的key反序列化之后是字符串
+	if values, _ := decoded.At("2"); !reflect.DeepEqual(values, []interface{}{"y"}) || decoded.Size() != 3 {
+		t.Fatalf("decoded = %v", decoded.ToMap())
+	}
+
+	var holder struct {
+		Roles *MultimapOf[string, string] `json:"roles"`
+	}
+	if err := json.Unmarshal([]byte(`{"roles":{"alice":["admin","editor"]}}`), &holder); err != nil {
+		t.Fatal(err)
+	}
+	holder.Roles.Remove("alice", "admin") // 零值的equal被初始化
+	if values, _ := holder.Roles.At("alice"); !reflect.DeepEqual(values, []string{"editor"}) {
+		t.Fatalf("roles = %v", values)
+	}
+
+	bad := NewMultimapOf[int, int]()
+	bad.Insert(1, 1)
+	if err := json.Unmarshal([]byte(`{"x":[1]}`), bad); err == nil {
+		t.Fatal("invalid int key was accepted")
+	}
+	if bad.Size() != 1 {
+		t.Fatal("failed unmarshal changed the multimap")
+	}
+}
+
+func TestBiMultimapJSONKeepsInverseLive(t *testing.T) {
+	m := NewBiMultimap[string, string]()
+	users := m.Inverse()
+	if err := json.Unmarshal([]byte(`{"alice":["admin"],"bob":["admin","admin"]}`), m); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := users.At("admin")
+	got = append([]string(nil), got...)
+	sort.Strings(got)
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(got, want) || m.Size() != 2 {
+		t.Fatalf("inverse after unmarshal = %v, size %d", got, m.Size())
+	}
+}
+
+func TestMultimapRemoval(t *testing.T) {
+	boolInt := func(ok bool) int {
+		if ok {
+			return 1
+		}
+		return 0
+	}
+	tests := []struct {
+		name   string
+		op     func(m *MultimapOf[string, int]) int // 返回方法的返回值，没有返回值时返回0
+		result int
+		want   map[string][]int // 剩余的values
+	}{
+		{
+			name: "Remove removes every equal value",
+			op:   func(m *MultimapOf[string, int]) int { m.Remove("a", 1); return 0 },
+			want: map[string][]int{"a": {2, 3}, "b": {1, 1}},
+		},
+		{
+			name: "Remove missing value",
+			op:   func(m *MultimapOf[string, int]) int { m.Remove("a", 9); return 0 },
+			want: map[string][]int{"a": {1, 1, 2, 1, 3, 1}, "b": {1, 1}},
+		},
+		{
+			name:   "RemoveIf",
+			op:     func(m *MultimapOf[string, int]) int { return m.RemoveIf("a", func(v int) bool { return v != 2 }) },
+			result: 5,
+			want:   map[string][]int{"a": {2}, "b": {1, 1}},
+		},
+		{
+			name:   "RemoveIf all values deletes the key",
+			op:     func(m *MultimapOf[string, int]) int { return m.RemoveIf("b", func(int) bool { return true }) },
+			result: 2,
+			want:   map[string][]int{"a": {1, 1, 2, 1, 3, 1}},
+		},
+		{
+			name:   "RemoveFirst removes one duplicate",
+			op:     func(m *MultimapOf[string, int]) int { return boolInt(m.RemoveFirst("a", 1)) },
+			result: 1,
+			want:   map[string][]int{"a": {1, 2, 1, 3, 1}, "b": {1, 1}},
+		},
+		{
+			name:   "RemoveFirst last duplicate deletes the key",
+			op:     func(m *MultimapOf[string, int]) int { return boolInt(m.RemoveFirst("b", 1) && m.RemoveFirst("b", 1)) },
+			result: 1,
+			want:   map[string][]int{"a": {1, 1, 2, 1, 3, 1}},
+		},
+		{
+			name: "RemoveFirst missing key",
+			op:   func(m *MultimapOf[string, int]) int { return boolInt(m.RemoveFirst("c", 1)) },
+			want: map[string][]int{"a": {1, 1, 2, 1, 3, 1}, "b": {1, 1}},
+		},
+		{
+			name:   "RemoveValueEverywhere",
+			op:     func(m *MultimapOf[string, int]) int { return m.RemoveValueEverywhere(1) },
+			result: 6,
+			want:   map[string][]int{"a": {2, 3}},
+		},
+		{
+			name: "Retain",
+			op: func(m *MultimapOf[string, int]) int {
+				return m.Retain(func(k string, v int) bool { return k == "a" && v != 3 })
+			},
+			result: 3,
+			want:   map[string][]int{"a": {1, 1, 2, 1, 1}},
+		},
+		{
+			name:   "ReplaceValues returns the old values",
+			op:     func(m *MultimapOf[string, int]) int { return len(m.ReplaceValues("a", []int{7, 7})) },
+			result: 6,
+			want:   map[string][]int{"a": {7, 7}, "b": {1, 1}},
+		},
+		{
+			name:   "ReplaceValues with nil deletes the key",
+			op:     func(m *MultimapOf[string, int]) int { return len(m.ReplaceValues("b", nil)) },
+			result: 2,
+			want:   map[string][]int{"a": {1, 1, 2, 1, 3, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMultimapOf[string, int]()
+			m.InsertValues("a", []int{1, 1, 2, 1, 3, 1})
+			m.InsertValues("b", []int{1, 1})
+			if result := tt.op(m); result != tt.result {
+				t.Errorf("result = %d, want %d", result, tt.result)
+			}
+			if got := m.ToMap(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+			size := 0
+			for _, values := range tt.want {
+				size += len(values)
+			}
+			if m.Size() != size || len(m.Keys()) != len(tt.want) {
+				t.Errorf("Size = %d, keys = %v, want %d, %d keys", m.Size(), m.Keys(), size, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMultimapRemoveKeepsReturnedSlices(t *testing.T) {
+	m := NewMultimapOf[string, int]()
+	m.InsertValues("a", []int{1, 2, 1, 3})
+	before, _ := m.At("a")
+	m.Remove("a", 1)
+	if want := []int{1, 2, 1, 3}; !reflect.DeepEqual(before, want) {
+		t.Fatalf("Remove changed the slice from At: %v, want %v", before, want)
+	}
+	afterRemove, _ := m.At("a")
+	m.RemoveFirst("a", 2)
+	if want := []int{2, 3}; !reflect.DeepEqual(afterRemove, want) {
+		t.Fatalf("RemoveFirst changed the slice from At: %v, want %v", afterRemove, want)
+	}
+	if values, _ := m.At("a"); !reflect.DeepEqual(values, []int{3}) || m.Size() != 1 {
+		t.Fatalf("values = %v, size %d", values, m.Size())
+	}
+	// 没有移除任何记录时不复制
+	kept, _ := m.At("a")
+	m.RemoveIf("a", func(int) bool { return false })
+	if values, _ := m.At("a"); &values[0] != &kept[0] {
+		t.Fatal("RemoveIf without removal replaced the slice")
+	}
+}
+
+func TestMultimapDefaultEqual(t *testing.T) {
+	type point struct{ X, Y int }
+	points := NewMultimapOf[string, point]()
+	points.InsertValues("a", []point{{1, 2}, {3, 4}})
+	points.Remove("a", point{1, 2})
+	if values, _ := points.At("a"); !reflect.DeepEqual(values, []point{{3, 4}}) {
+		t.Fatalf("points = %v", values)
+	}
+
+	lists := NewMultimapOf[string, []int]()
+	lists.InsertValues("a", [][]int{{1}, {2}})
+	lists.Remove("a", []int{1})
+	if values, _ := lists.At("a"); !reflect.DeepEqual(values, [][]int{{2}}) {
+		t.Fatalf("slices = %v", values)
+	}
+
+	// 包含接口的结构体可以比较，但是接口里是切片时==会panic
+	type tagged struct{ Tag interface{} }
+	tags := NewMultimapOf[string, tagged]()
+	tags.InsertValues("a", []tagged{{[]int{1}}, {"x"}, {nil}})
+	tags.Remove("a", tagged{[]int{1}})
+	tags.Remove("a", tagged{nil})
+	if values, _ := tags.At("a"); !reflect.DeepEqual(values, []tagged{{"x"}}) {
+		t.Fatalf("tags = %v", values)
+	}
+
+	mixed := NewMultimap()
+	mixed.InsertValues("a", []interface{}{1, "1", []int{1}, nil})
+	mixed.Remove("a", []int{1})
+	mixed.Remove("a", 1)
+	mixed.Remove("a", nil)
+	if values, _ := mixed.At("a"); !reflect.DeepEqual(values, []interface{}{"1"}) {
+		t.Fatalf("interface values = %v", values)
+	}
+
+	for _, tt := range []struct {
+		value interface{}
+		want  bool
+	}{
+		{0, true},
+		{point{}, true},
+		{[2]string{}, true},
+		{tagged{}, false},
+		{[1]interface{}{}, false},
+		{[]int{}, false},
+	} {
+		if got := strictlyComparable(reflect.TypeOf(tt.value)); got != tt.want {
+			t.Errorf("strictlyComparable(%T) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// 检查正向和反向索引包含同样的键值对
+func checkBiMultimap(t *testing.T, m *BiMultimap[string, string], want map[string][]string) {
+	t.Helper()
+	if got := m.ToMap(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("forward = %v, want %v", got, want)
+	}
+	inverse := make(map[string][]string)
+	for key, values := range want {
+		for _, value := range values {
+			inverse[value] = append(inverse[value], key)
+		}
+	}
+	got := m.Inverse().ToMap()
+	for _, keys := range got {
+		sort.Strings(keys)
+	}
+	for _, keys := range inverse {
+		sort.Strings(keys)
+	}
+	if !reflect.DeepEqual(got, inverse) {
+		t.Fatalf("inverse = %v, want %v", got, inverse)
+	}
+	size := 0
+	for _, values := range want {
+		size += len(values)
+	}
+	if m.Size() != size || m.Inverse().Size() != size {
+		t.Fatalf("Size = %d, inverse Size = %d, want %d", m.Size(), m.Inverse().Size(), size)
+	}
+}
+
+func TestBiMultimapKeepsBothIndexesConsistent(t *testing.T) {
+	m := NewBiMultimap[string, string]()
+	m.InsertValues("alice", []string{"admin", "editor", "admin"})
+	m.Insert("bob", "editor")
+	m.Insert("carol", "viewer")
+	checkBiMultimap(t, m, map[string][]string{"alice": {"admin", "editor"}, "bob": {"editor"}, "carol": {"viewer"}})
+
+	m.Remove("alice", "editor")
+	m.Remove("alice", "missing")
+	checkBiMultimap(t, m, map[string][]string{"alice": {"admin"}, "bob": {"editor"}, "carol": {"viewer"}})
+
+	m.RemoveAll("carol")
+	checkBiMultimap(t, m, map[string][]string{"alice": {"admin"}, "bob": {"editor"}})
+
+	m.Insert("bob", "admin")
+	m.RemoveValue("admin")
+	checkBiMultimap(t, m, map[string][]string{"bob": {"editor"}})
+	if m.Contains("alice", "admin") || m.Inverse().Contains("admin", "bob") {
+		t.Fatal("removed pair is still present")
+	}
+}
+
+func TestBiMultimapInverseView(t *testing.T) {
+	m := NewBiMultimap[string, string]()
+	users := m.Inverse()
+	m.Insert("alice", "admin")
+	users.Insert("editor", "bob") // 通过视图插入，原来的multimap也能看到
+	users.Insert("editor", "alice")
+	checkBiMultimap(t, m, map[string][]string{"alice": {"admin", "editor"}, "bob": {"editor"}})
+	if keys, _ := users.At("admin"); !reflect.DeepEqual(keys, []string{"alice"}) {
+		t.Fatalf("view At(admin) = %v", keys)
+	}
+
+	users.RemoveAll("editor")
+	checkBiMultimap(t, m, map[string][]string{"alice": {"admin"}})
+	users.Remove("admin", "alice")
+	if !m.IsEmpty() || !users.IsEmpty() {
+		t.Fatalf("after removing through the view: %v, %v", m.ToMap(), users.ToMap())
+	}
+	if back := users.Inverse(); back.forward != m.forward || back.inverse != m.inverse {
+		t.Fatal("Inverse of the view does not share data with the original")
+	}
+}
+
+func TestSetMultimapRemoveKeepsReturnedSlices(t *testing.T) {
+	m := NewSetMultimap[string, int]()
+	m.InsertValues("a", []int{1, 2, 3})
+	before, _ := m.At("a")
+	m.Remove("a", 1)
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(before, want) {
+		t.Fatalf("Remove changed the slice from At: %v, want %v", before, want)
+	}
+	if values, _ := m.At("a"); !reflect.DeepEqual(values, []int{2, 3}) || !m.Contains("a", 3) {
+		t.Fatalf("values = %v", values)
+	}
+	// 移除之后的位置索引仍然正确
+	m.Remove("a", 3)
+	m.Insert("a", 4)
+	if values, _ := m.At("a"); !reflect.DeepEqual(values, []int{2, 4}) || m.Size() != 2 {
+		t.Fatalf("values = %v, size %d", values, m.Size())
+	}
+}
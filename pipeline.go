@@ -0,0 +1,237 @@
+package lodago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 多阶段的流水线，每个阶段有自己的处理函数、协程数量和输入队列，阶段之间通过有界的Queue连接，
+// 下游处理不过来时队列满了，上游的协程阻塞在插入上（背压），最终阻塞Put。
+// Stop按照阶段的顺序关闭队列，每个阶段处理完剩余的记录之后再关闭下一个阶段。
+
+// ErrSkipItem 阶段函数返回这个错误时丢弃记录，不传给下一个阶段，也不算作错误
+var ErrSkipItem = errors.New("Skip item")
+
+// ErrPipelineStarted 流水线已经启动，不能再添加阶段或者重复启动
+var ErrPipelineStarted = errors.New("Pipeline is started")
+
+// StageFunc 阶段函数，返回值传给下一个阶段，最后一个阶段的返回值被丢弃
+type StageFunc func(item interface{}) (interface{}, error)
+
+// StageOptions 阶段的选项
+type StageOptions struct {
+	Workers   int                               // 协程数量，默认1
+	QueueSize uint64                            // 输入队列的容量，默认1024
+	OnError   func(item interface{}, err error) // 阶段函数返回错误或者panic时调用
+}
+
+// 默认的阶段输入队列容量
+const defaultStageQueueSize = 1024
+
+// 流水线的阶段
+type pipelineStage struct {
+	name     string
+	fn       StageFunc
+	options  StageOptions
+	queue    *Queue
+	pool     *WorkerPool[interface{}]
+	success  Counter
+	failure  Counter
+	skipped  Counter
+	duration *Histogram
+}
+
+// Pipeline 多阶段的流水线
+type Pipeline struct {
+	name    string
+	stages  []*pipelineStage
+	err     error // 添加阶段时的错误，Start时返回
+	started bool
+	stopped bool
+	locker  sync.Mutex
+}
+
+// NewPipeline 创建流水线
+func NewPipeline(name string) *Pipeline {
+	return &Pipeline{name: name}
+}
+
+// Name 流水线名称
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// AddStage 添加阶段，按照添加的顺序执行，options为可选的选项，可以链式调用，错误在Start时返回
+func (p *Pipeline) AddStage(name string, fn StageFunc, options ...StageOptions) *Pipeline {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.err != nil {
+		return p
+	}
+	if p.started {
+		p.err = ErrPipelineStarted
+		return p
+	}
+	if name == "" || fn == nil {
+		p.err = errors.New("Stage name or function is empty")
+		return p
+	}
+	for _, stage := range p.stages {
+		if stage.name == name {
+			p.err = fmt.Errorf("Stage %s already exists", name)
+			return p
+		}
+	}
+	stage := &pipelineStage{name: name, fn: fn, duration: NewHistogram()}
+	if len(options) > 0 {
+		stage.options = options[0]
+	}
+	if stage.options.QueueSize == 0 {
+		stage.options.QueueSize = defaultStageQueueSize
+	}
+	stage.queue = NewQueue(stage.options.QueueSize, time.Microsecond)
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Start 启动所有阶段的协程
+func (p *Pipeline) Start() error {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if p.started {
+		return ErrPipelineStarted
+	}
+	if len(p.stages) == 0 {
+		return fmt.Errorf("Pipeline %s has no stages", p.name)
+	}
+	p.started = true
+	for i, stage := range p.stages {
+		var next *pipelineStage
+		if i+1 < len(p.stages) {
+			next = p.stages[i+1]
+		}
+		stage.pool = NewWorkerPool(stage.queue, p.handler(stage, next), WorkerPoolOptions[interface{}]{
+			Workers: stage.options.Workers,
+			OnError: func(batch []interface{}, err error) { // 阶段函数panic
+				stage.failure.Inc()
+				if stage.options.OnError != nil {
+					stage.options.OnError(batch[0], err)
+				}
+			},
+		})
+		stage.pool.Start()
+	}
+	return nil
+}
+
+// Put 向第一个阶段插入记录，队列已满时阻塞（背压），直到插入成功或者ctx结束，流水线停止之后返回ErrQueueClosed
+func (p *Pipeline) Put(ctx context.Context, item interface{}) error {
+	p.locker.Lock()
+	if len(p.stages) == 0 {
+		p.locker.Unlock()
+		return fmt.Errorf("Pipeline %s has no stages", p.name)
+	}
+	queue := p.stages[0].queue
+	p.locker.Unlock()
+	return queue.PutContext(ctx, item)
+}
+
+// Resize 调整阶段的协程数量
+func (p *Pipeline) Resize(stageName string, workers int) error {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	for _, stage := range p.stages {
+		if stage.name != stageName {
+			continue
+		}
+		stage.options.Workers = workers
+		if stage.pool != nil {
+			stage.pool.Resize(workers)
+		}
+		return nil
+	}
+	return fmt.Errorf("Stage %s does not exist", stageName)
+}
+
+// Stop 按照阶段的顺序停止，每个阶段处理完队列中剩余的记录并传给下一个阶段之后，再停止下一个阶段，
+// 全部处理完之后返回，重复调用没有影响
+func (p *Pipeline) Stop() {
+	p.locker.Lock()
+	if p.stopped {
+		p.locker.Unlock()
+		return
+	}
+	p.stopped = true
+	stages := p.stages
+	p.locker.Unlock()
+	for _, stage := range stages {
+		if stage.pool != nil {
+			stage.pool.Stop()
+		} else {
+			stage.queue.Close()
+		}
+	}
+}
+
+// Collect 收集每个阶段的指标，实现Collector接口
+func (p *Pipeline) Collect() []Metric {
+	p.locker.Lock()
+	stages := p.stages
+	p.locker.Unlock()
+	items := Metric{Name: "lodago_pipeline_stage_items_total", Help: "Number of items processed by the stage, by result.", Type: CounterMetric}
+	queued := Metric{Name: "lodago_pipeline_stage_queue_quantity", Help: "Number of items waiting in the stage input queue.", Type: GaugeMetric}
+	capacity := Metric{Name: "lodago_pipeline_stage_queue_capacity", Help: "Capacity of the stage input queue.", Type: GaugeMetric}
+	workers := Metric{Name: "lodago_pipeline_stage_workers", Help: "Number of workers of the stage.", Type: GaugeMetric}
+	duration := Metric{Name: "lodago_pipeline_stage_duration_seconds", Help: "Duration of the stage function.", Type: HistogramMetric}
+	for _, stage := range stages {
+		labels := map[string]string{"pipeline": p.name, "stage": stage.name}
+		items.Samples = append(items.Samples,
+			MetricSample{Labels: map[string]string{"pipeline": p.name, "stage": stage.name, "status": "success"}, Value: float64(stage.success.Value())},
+			MetricSample{Labels: map[string]string{"pipeline": p.name, "stage": stage.name, "status": "failure"}, Value: float64(stage.failure.Value())},
+			MetricSample{Labels: map[string]string{"pipeline": p.name, "stage": stage.name, "status": "skipped"}, Value: float64(stage.skipped.Value())},
+		)
+		queued.Samples = append(queued.Samples, MetricSample{Labels: labels, Value: float64(stage.queue.GetQuantity())})
+		capacity.Samples = append(capacity.Samples, MetricSample{Labels: labels, Value: float64(stage.queue.GetCapacity())})
+		count := 0
+		if stage.pool != nil {
+			count = stage.pool.Workers()
+		}
+		workers.Samples = append(workers.Samples, MetricSample{Labels: labels, Value: float64(count)})
+		duration.Samples = append(duration.Samples, stage.duration.Sample(labels))
+	}
+	return []Metric{items, queued, capacity, workers, duration}
+}
+
+// handler 阶段协程的处理函数，处理成功之后阻塞地插入下一个阶段的队列
+func (p *Pipeline) handler(stage, next *pipelineStage) func([]interface{}) error {
+	return func(batch []interface{}) error {
+		for _, item := range batch {
+			start := time.Now()
+			out, err := stage.fn(item)
+			stage.duration.Observe(time.Since(start).Seconds())
+			if err == ErrSkipItem {
+				stage.skipped.Inc()
+				continue
+			}
+			if err == nil && next != nil {
+				// 下一个阶段在这个阶段停止之后才会关闭，这里只有下游处理不过来时才会阻塞
+				err = next.queue.PutContext(context.Background(), out)
+			}
+			if err != nil {
+				stage.failure.Inc()
+				if stage.options.OnError != nil {
+					stage.options.OnError(item, err)
+				}
+				continue
+			}
+			stage.success.Inc()
+		}
+		return nil
+	}
+}
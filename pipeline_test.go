@@ -0,0 +1,189 @@
+package lodago
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pipelineSink 最后一个阶段，按照到达的顺序收集记录
+type pipelineSink struct {
+	mu    sync.Mutex
+	items []interface{}
+}
+
+func (s *pipelineSink) stage(item interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, item)
+	return nil, nil
+}
+
+func (s *pipelineSink) values() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]interface{}(nil), s.items...)
+}
+
+// 每个阶段一个协程时，记录按照插入的顺序依次经过每个阶段
+func TestPipelineStageOrder(t *testing.T) {
+	sink := &pipelineSink{}
+	p := NewPipeline("order").
+		AddStage("inc", func(item interface{}) (interface{}, error) { return item.(int) + 1, nil }).
+		AddStage("odd", func(item interface{}) (interface{}, error) {
+			if item.(int)%2 == 0 {
+				return nil, ErrSkipItem
+			}
+			return item, nil
+		}).
+		AddStage("double", func(item interface{}) (interface{}, error) { return item.(int) * 2, nil }).
+		AddStage("sink", sink.stage)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var want []interface{}
+	for i := 0; i < 50; i++ {
+		if err := p.Put(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+		if (i+1)%2 == 1 {
+			want = append(want, (i+1)*2)
+		}
+	}
+	p.Stop()
+	if got := sink.values(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sink = %v, want %v", got, want)
+	}
+	labels := map[string]string{"stage": "odd", "status": "skipped"}
+	if v, _ := findSample(p.Collect(), "lodago_pipeline_stage_items_total", labels); v != 25 {
+		t.Fatalf("skipped = %v, want 25", v)
+	}
+}
+
+// 下游阻塞时队列依次被填满，最终Put阻塞直到ctx超时
+func TestPipelineBackPressure(t *testing.T) {
+	sink := &pipelineSink{}
+	gate := make(chan struct{})
+	var blocked int32
+	p := NewPipeline("pressure").
+		AddStage("first", func(item interface{}) (interface{}, error) { return item, nil }, StageOptions{QueueSize: 1}).
+		AddStage("slow", func(item interface{}) (interface{}, error) {
+			atomic.AddInt32(&blocked, 1)
+			<-gate
+			return sink.stage(item)
+		}, StageOptions{QueueSize: 1})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	p.Put(ctx, "a")
+	waitFor(t, "slow stage to block", func() bool { return atomic.LoadInt32(&blocked) == 1 })
+	// b在slow的队列中，c阻塞在first的协程插入slow的队列，d在first的队列中
+	for _, item := range []string{"b", "c", "d"} {
+		if err := p.Put(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.Put(timeout, "e"); err != context.DeadlineExceeded {
+		t.Fatalf("Put into a full pipeline = %v, want DeadlineExceeded", err)
+	}
+	close(gate)
+	p.Stop()
+	if got, want := sink.values(), []interface{}{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sink = %v, want %v", got, want)
+	}
+}
+
+// 减少阶段的协程数量，输入队列中还有积压时也会生效
+func TestPipelineResize(t *testing.T) {
+	var started, running, initialDone, maxAfter int32
+	gate := make(chan struct{})
+	p := NewPipeline("resize").AddStage("work", func(item interface{}) (interface{}, error) {
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if atomic.AddInt32(&started, 1) <= 3 {
+			<-gate
+			atomic.AddInt32(&initialDone, 1)
+			return item, nil
+		}
+		if atomic.LoadInt32(&initialDone) == 3 && cur > atomic.LoadInt32(&maxAfter) {
+			atomic.StoreInt32(&maxAfter, cur)
+		}
+		time.Sleep(100 * time.Microsecond)
+		return item, nil
+	}, StageOptions{Workers: 3})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		p.Put(context.Background(), i)
+	}
+	waitFor(t, "3 running workers", func() bool { return atomic.LoadInt32(&running) == 3 })
+	if err := p.Resize("work", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Resize("missing", 1); err == nil {
+		t.Fatal("Resize of an unknown stage succeeded")
+	}
+	if v, _ := findSample(p.Collect(), "lodago_pipeline_stage_workers", map[string]string{"stage": "work"}); v != 1 {
+		t.Fatalf("workers = %v, want 1", v)
+	}
+	close(gate)
+	p.Stop()
+	if started != 100 {
+		t.Fatalf("processed %d items, want 100", started)
+	}
+	if maxAfter > 1 {
+		t.Fatalf("%d workers ran at once after Resize", maxAfter)
+	}
+}
+
+// Stop按照阶段的顺序关闭，上游剩余的记录都能进入下游，没有记录因为下游队列关闭而失败
+func TestPipelineStopDrainsStagesInOrder(t *testing.T) {
+	sink := &pipelineSink{}
+	var p *Pipeline
+	var closedEarly int32
+	p = NewPipeline("drain").
+		AddStage("slow", func(item interface{}) (interface{}, error) {
+			time.Sleep(100 * time.Microsecond)
+			return item, nil
+		}, StageOptions{Workers: 2}).
+		AddStage("check", func(item interface{}) (interface{}, error) {
+			if p.stages[2].queue.IsClosed() { // 这个阶段还在处理时，下一个阶段不能关闭
+				atomic.AddInt32(&closedEarly, 1)
+			}
+			return item, nil
+		}).
+		AddStage("sink", sink.stage)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		p.Put(context.Background(), i)
+	}
+	p.Stop()
+	p.Stop() // 重复调用没有影响
+	if got := len(sink.values()); got != 200 {
+		t.Fatalf("sink received %d items, want 200", got)
+	}
+	if closedEarly != 0 {
+		t.Fatalf("%d items saw the next stage closed before their stage stopped", closedEarly)
+	}
+	metrics := p.Collect()
+	for _, stage := range []string{"slow", "check", "sink"} {
+		if v, _ := findSample(metrics, "lodago_pipeline_stage_items_total", map[string]string{"stage": stage, "status": "failure"}); v != 0 {
+			t.Errorf("stage %s failures = %v", stage, v)
+		}
+		if v, _ := findSample(metrics, "lodago_pipeline_stage_queue_quantity", map[string]string{"stage": stage}); v != 0 {
+			t.Errorf("stage %s has %v items left", stage, v)
+		}
+	}
+	if err := p.Put(context.Background(), 1); err != ErrQueueClosed {
+		t.Fatalf("Put after Stop = %v, want ErrQueueClosed", err)
+	}
+}
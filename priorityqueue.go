@@ -0,0 +1,252 @@
+package lodago
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// 基于二叉堆的优先级队列，less(a, b)为true时a先出队，优先级相同的元素按照插入顺序出队。
+// PriorityQueue不是线程安全的，多个协程使用时请用SyncPriorityQueue。
+// SyncPriorityQueue关闭之后不能再插入，和Queue一样，剩余的元素仍然可以取出，取完之后PopContext返回ErrQueueClosed。
+
+// PriorityItem 优先级队列中的元素句柄，用于更新优先级和删除
+type PriorityItem[T any] struct {
+	value T
+	seq   uint64 // 插入序号，优先级相同时保证先进先出
+	index int    // 在堆中的位置，-1表示已经不在队列中
+	owner *PriorityQueue[T]
+}
+
+// Value 元素的值
+func (item *PriorityItem[T]) Value() T {
+	return item.value
+}
+
+// 实现heap.Interface
+type pqHeap[T any] struct {
+	items []*PriorityItem[T]
+	less  func(a, b T) bool
+}
+
+func (h *pqHeap[T]) Len() int {
+	return len(h.items)
+}
+
+func (h *pqHeap[T]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.value, b.value) {
+		return true
+	}
+	if h.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (h *pqHeap[T]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *pqHeap[T]) Push(x interface{}) {
+	item := x.(*PriorityItem[T])
+	item.index = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *pqHeap[T]) Pop() interface{} {
+	n := len(h.items) - 1
+	item := h.items[n]
+	h.items[n] = nil // 释放引用
+	h.items = h.items[:n]
+	item.index = -1
+	return item
+}
+
+// PriorityQueue 优先级队列
+type PriorityQueue[T any] struct {
+	heap pqHeap[T]
+	seq  uint64
+}
+
+// NewPriorityQueue 创建优先级队列，less(a, b)为true时a的优先级更高
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{heap: pqHeap[T]{less: less}}
+}
+
+// Len 队列中元素的数量
+func (pq *PriorityQueue[T]) Len() int {
+	return pq.heap.Len()
+}
+
+// Push 插入元素，返回元素的句柄
+func (pq *PriorityQueue[T]) Push(value T) *PriorityItem[T] {
+	pq.seq++
+	item := &PriorityItem[T]{value: value, seq: pq.seq, owner: pq}
+	heap.Push(&pq.heap, item)
+	return item
+}
+
+// Pop 取出优先级最高的元素，队列为空时返回false
+func (pq *PriorityQueue[T]) Pop() (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	item := heap.Pop(&pq.heap).(*PriorityItem[T])
+	return item.value, true
+}
+
+// Peek 查看优先级最高的元素但不取出，队列为空时返回false
+func (pq *PriorityQueue[T]) Peek() (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.heap.items[0].value, true
+}
+
+// Update 更新元素的值并调整位置，元素已经出队或者不属于这个队列时返回false
+func (pq *PriorityQueue[T]) Update(item *PriorityItem[T], value T) bool {
+	if !pq.contains(item) {
+		return false
+	}
+	item.value = value
+	heap.Fix(&pq.heap, item.index)
+	return true
+}
+
+// Remove 删除元素，元素已经出队或者不属于这个队列时返回false
+func (pq *PriorityQueue[T]) Remove(item *PriorityItem[T]) bool {
+	if !pq.contains(item) {
+		return false
+	}
+	heap.Remove(&pq.heap, item.index)
+	return true
+}
+
+// Clear 清空队列
+func (pq *PriorityQueue[T]) Clear() {
+	for _, item := range pq.heap.items {
+		item.index = -1
+	}
+	pq.heap.items = nil
+}
+
+// contains 元素是否还在这个队列中
+func (pq *PriorityQueue[T]) contains(item *PriorityItem[T]) bool {
+	return item != nil && item.owner == pq && item.index >= 0
+}
+
+// SyncPriorityQueue 线程安全的优先级队列
+type SyncPriorityQueue[T any] struct {
+	pq       *PriorityQueue[T]
+	closed   bool
+	locker   sync.Mutex
+	notEmpty qnotifier
+}
+
+// NewSyncPriorityQueue 创建线程安全的优先级队列，less(a, b)为true时a的优先级更高
+func NewSyncPriorityQueue[T any](less func(a, b T) bool) *SyncPriorityQueue[T] {
+	return &SyncPriorityQueue[T]{pq: NewPriorityQueue(less)}
+}
+
+// Len 队列中元素的数量
+func (q *SyncPriorityQueue[T]) Len() int {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.pq.Len()
+}
+
+// Push 插入元素，返回元素的句柄，并唤醒等待的PopContext，队列已经关闭时不插入，返回nil
+func (q *SyncPriorityQueue[T]) Push(value T) *PriorityItem[T] {
+	q.locker.Lock()
+	if q.closed {
+		q.locker.Unlock()
+		return nil
+	}
+	item := q.pq.Push(value)
+	q.locker.Unlock()
+	q.notEmpty.broadcast()
+	return item
+}
+
+// Pop 取出优先级最高的元素，队列为空时立即返回false
+func (q *SyncPriorityQueue[T]) Pop() (T, bool) {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.pq.Pop()
+}
+
+// PopContext 取出优先级最高的元素，队列为空时阻塞，直到有元素或者ctx结束，
+// 队列关闭并且已经取完时返回ErrQueueClosed
+func (q *SyncPriorityQueue[T]) PopContext(ctx context.Context) (T, error) {
+	for {
+		q.locker.Lock()
+		if value, ok := q.pq.Pop(); ok {
+			q.locker.Unlock()
+			return value, nil
+		}
+		if q.closed {
+			q.locker.Unlock()
+			var zero T
+			return zero, ErrQueueClosed
+		}
+		// 在锁内登记，Push和Close在释放锁之后通知，不会丢失唤醒
+		ch := q.notEmpty.register()
+		q.locker.Unlock()
+		select {
+		case <-ch:
+			q.notEmpty.unregister()
+		case <-ctx.Done():
+			q.notEmpty.unregister()
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Close 关闭队列，之后的插入都会失败，唤醒阻塞的PopContext，重复关闭没有影响
+func (q *SyncPriorityQueue[T]) Close() {
+	q.locker.Lock()
+	q.closed = true
+	q.locker.Unlock()
+	q.notEmpty.broadcast()
+}
+
+// IsClosed 队列是否已经关闭
+func (q *SyncPriorityQueue[T]) IsClosed() bool {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.closed
+}
+
+// Peek 查看优先级最高的元素但不取出，队列为空时返回false
+func (q *SyncPriorityQueue[T]) Peek() (T, bool) {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.pq.Peek()
+}
+
+// Update 更新元素的值并调整位置，元素已经出队或者不属于这个队列时返回false
+func (q *SyncPriorityQueue[T]) Update(item *PriorityItem[T], value T) bool {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.pq.Update(item, value)
+}
+
+// Remove 删除元素，元素已经出队或者不属于这个队列时返回false
+func (q *SyncPriorityQueue[T]) Remove(item *PriorityItem[T]) bool {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	return q.pq.Remove(item)
+}
+
+// Clear 清空队列
+func (q *SyncPriorityQueue[T]) Clear() {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	q.pq.Clear()
+}
@@ -0,0 +1,159 @@
+package lodago
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type pqTask struct {
+	priority int
+	name     string
+}
+
+func pqTaskLess(a, b pqTask) bool {
+	return a.priority > b.priority
+}
+
+// popAll 依次取出所有元素的名称
+func popAll(pq *PriorityQueue[pqTask]) []string {
+	var names []string
+	for {
+		task, ok := pq.Pop()
+		if !ok {
+			return names
+		}
+		names = append(names, task.name)
+	}
+}
+
+// 优先级相同的元素按照插入顺序出队
+func TestPriorityQueueStableOrder(t *testing.T) {
+	pq := NewPriorityQueue(pqTaskLess)
+	for i, task := range []pqTask{{1, "a"}, {2, "b"}, {1, "c"}, {2, "d"}, {1, "e"}, {3, "f"}, {2, "g"}} {
+		pq.Push(task)
+		if pq.Len() != i+1 {
+			t.Fatalf("Len = %d, want %d", pq.Len(), i+1)
+		}
+	}
+	if task, ok := pq.Peek(); !ok || task.name != "f" {
+		t.Fatalf("Peek = %v, %v, want f", task, ok)
+	}
+	if got, want := popAll(pq), []string{"f", "b", "d", "g", "a", "c", "e"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	if _, ok := pq.Pop(); ok {
+		t.Fatal("Pop on empty queue returned a value")
+	}
+}
+
+func TestPriorityQueueUpdateRemove(t *testing.T) {
+	pq := NewPriorityQueue(pqTaskLess)
+	a := pq.Push(pqTask{1, "a"})
+	b := pq.Push(pqTask{2, "b"})
+	c := pq.Push(pqTask{3, "c"})
+	pq.Push(pqTask{2, "d"})
+	if !pq.Update(a, pqTask{5, "a"}) || a.Value().priority != 5 {
+		t.Fatal("Update failed")
+	}
+	// 更新之后和优先级相同的元素比较时保持原来的插入顺序
+	if !pq.Update(c, pqTask{2, "c"}) {
+		t.Fatal("Update failed")
+	}
+	if !pq.Remove(b) {
+		t.Fatal("Remove failed")
+	}
+	if got, want := popAll(pq), []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+
+	// 过期的句柄：已经删除、已经出队、属于其他队列或者被Clear
+	if pq.Remove(b) || pq.Update(b, pqTask{9, "b"}) {
+		t.Fatal("removed handle was accepted")
+	}
+	if pq.Remove(a) || pq.Update(a, pqTask{9, "a"}) {
+		t.Fatal("popped handle was accepted")
+	}
+	other := NewPriorityQueue(pqTaskLess)
+	foreign := other.Push(pqTask{1, "x"})
+	if pq.Remove(foreign) || pq.Update(foreign, pqTask{9, "x"}) || pq.Remove(nil) {
+		t.Fatal("foreign or nil handle was accepted")
+	}
+	cleared := pq.Push(pqTask{1, "y"})
+	pq.Clear()
+	if pq.Len() != 0 || pq.Remove(cleared) || pq.Update(cleared, pqTask{9, "y"}) {
+		t.Fatal("handle was accepted after Clear")
+	}
+	if other.Len() != 1 {
+		t.Fatalf("other queue Len = %d, want 1", other.Len())
+	}
+}
+
+func TestSyncPriorityQueuePopContext(t *testing.T) {
+	q := NewSyncPriorityQueue(pqTaskLess)
+	type result struct {
+		task pqTask
+		err  error
+	}
+	pop := func(ctx context.Context) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			task, err := q.PopContext(ctx)
+			ch <- result{task, err}
+		}()
+		return ch
+	}
+	wait := func(ch <-chan result) result {
+		t.Helper()
+		select {
+		case r := <-ch:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("PopContext did not return")
+		}
+		return result{}
+	}
+
+	// 阻塞直到有元素插入
+	ch := pop(context.Background())
+	waitForWaiters(t, &q.notEmpty)
+	q.Push(pqTask{1, "a"})
+	if r := wait(ch); r.err != nil || r.task.name != "a" {
+		t.Fatalf("PopContext = %v, %v, want a", r.task, r.err)
+	}
+
+	// 取消
+	ctx, cancel := context.WithCancel(context.Background())
+	ch = pop(ctx)
+	waitForWaiters(t, &q.notEmpty)
+	cancel()
+	if r := wait(ch); r.err != context.Canceled {
+		t.Fatalf("PopContext after cancel = %v, want context.Canceled", r.err)
+	}
+
+	// 关闭唤醒阻塞的PopContext
+	ch = pop(context.Background())
+	waitForWaiters(t, &q.notEmpty)
+	q.Close()
+	if r := wait(ch); r.err != ErrQueueClosed {
+		t.Fatalf("PopContext after Close = %v, want ErrQueueClosed", r.err)
+	}
+	if !q.IsClosed() || q.Push(pqTask{1, "b"}) != nil || q.Len() != 0 {
+		t.Fatal("Push succeeded after Close")
+	}
+
+	// 关闭之前插入的元素仍然可以取出
+	q = NewSyncPriorityQueue(pqTaskLess)
+	q.Push(pqTask{1, "low"})
+	q.Push(pqTask{2, "high"})
+	q.Close()
+	for _, want := range []string{"high", "low"} {
+		if task, err := q.PopContext(context.Background()); err != nil || task.name != want {
+			t.Fatalf("PopContext = %v, %v, want %s", task, err, want)
+		}
+	}
+	if _, err := q.PopContext(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("PopContext on drained queue = %v, want ErrQueueClosed", err)
+	}
+}
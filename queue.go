@@ -1,8 +1,13 @@
 package lodago
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -11,33 +16,81 @@ import (
 // https://github.com/yireyun/go-queue
 // https://github.com/golangCasQueue/casQueue
 
-// 缓存
-type qcache struct {
+// ErrQueueClosed 队列已经关闭，插入失败，或者取出时已经没有剩余的记录
+var ErrQueueClosed = errors.New("Queue is closed")
+
+// qClosedBit putPos的最高位，表示队列已经关闭。关闭标记和putPos在同一个变量里，
+// 关闭之后插入时的CAS一定失败，不会有记录在关闭之后进入队列。
+const qClosedBit = uint64(1) << 63
+
+// cacheLinePad 缓存行的大小，用于填充，避免不同核心频繁写入的变量落在同一个缓存行（伪共享）
+const cacheLinePad = 64
+
+// 缓存，value后面填充一个完整的缓存行。元素大小不固定，无法对齐到缓存行，
+// 但是相邻位置之间至少间隔一个缓存行，一个位置的序号和值不会和相邻位置落在同一个缓存行（伪共享）
+type qcache[T any] struct {
 	putNo uint64
 	getNo uint64
-	value interface{}
+	value T
+	_     [cacheLinePad]byte
+}
+
+// Queuer 队列的通用接口，QueueOf、SPSCQueueOf、MPSCQueueOf、RingQueueOf和UnboundedQueueOf都实现了这个接口
+type Queuer[T any] interface {
+	Put(value T) (bool, uint64)
+	Get() (T, bool, uint64)
+	Puts(values []T) (int, uint64)
+	Gets(values []T) (int, uint64)
+	GetCapacity() uint64
+	GetQuantity() uint64
+	ToString() string
 }
 
-// Queue 无锁队列
-type Queue struct {
+// Queue 无锁队列，元素类型为interface{}，等同于QueueOf[interface{}]
+type Queue = QueueOf[interface{}]
+
+// QueueOf 元素类型为T的无锁队列，值类型的元素直接保存在缓存中，插入时不需要装箱分配内存，取出时不需要类型断言
+type QueueOf[T any] struct {
+	_      [cacheLinePad]byte
+	putPos uint64 // 生产者频繁写入，独占一个缓存行
+	_      [cacheLinePad - 8]byte
+	getPos uint64 // 消费者频繁写入，独占一个缓存行
+	_      [cacheLinePad - 8]byte
+	// 创建之后只读的成员
 	capacity  uint64
 	capMod    uint64
-	putPos    uint64
-	getPos    uint64
-	cache     []qcache
+	cache     []qcache[T]
 	sleepTime time.Duration
+	_         [cacheLinePad]byte
+	// 指标
+	putFails   uint64 // 插入失败的次数
+	getFails   uint64 // 取出失败的次数
+	casRetries uint64 // CAS竞争失败的次数
+	_          [cacheLinePad - 24]byte
+	// 阻塞等待
+	notEmpty qnotifier // 插入数据后通知等待取出的协程
+	notFull  qnotifier // 取出数据后通知等待插入的协程
 }
 
+var _ Queuer[int] = (*QueueOf[int])(nil)
+
 // NewQueue 创建一个队列
 func NewQueue(capacity uint64, sleepTime time.Duration) *Queue {
-	q := new(Queue)
+	return NewQueueOf[interface{}](capacity, sleepTime)
+}
+
+// NewQueueOf 创建一个元素类型为T的队列，容量向上取整到2的幂。
+// 为了避免伪共享，每个位置除了值和16字节的序号之外还有64字节的填充，例如T为int时每个位置占88字节，
+// 是值本身的11倍，容量为100万时（实际为1048576）需要约88MB内存，元素较小而容量很大时需要注意内存占用
+func NewQueueOf[T any](capacity uint64, sleepTime time.Duration) *QueueOf[T] {
+	q := new(QueueOf[T])
 	// 初始化内部成员变量
 	q.capacity = minQuantity(capacity) // TODO: 什么意思？
 	q.capMod = q.capacity - 1
 	q.putPos = 0
 	q.getPos = 0
 	q.sleepTime = sleepTime
-	q.cache = make([]qcache, q.capacity)
+	q.cache = make([]qcache[T], q.capacity)
 	for i := range q.cache {
 		cache := &q.cache[i]
 		// 初始化cache内部成员
@@ -51,195 +104,604 @@ func NewQueue(capacity uint64, sleepTime time.Duration) *Queue {
 }
 
 // ToString 序列化成字符串
-func (q *Queue) ToString() string {
+func (q *QueueOf[T]) ToString() string {
 	getPos := atomic.LoadUint64(&q.getPos) // 必须要使用原子操作获取
 	putPos := atomic.LoadUint64(&q.putPos) // 必须要使用原子操作获取
-	return fmt.Sprintf("Queue{capacity: %v, capMod: %v, putPos: %v, getPos: %v}",
-		q.capacity, q.capMod, putPos, getPos)
+	return fmt.Sprintf("Queue{capacity: %v, capMod: %v, putPos: %v, getPos: %v, closed: %v}",
+		q.capacity, q.capMod, putPos&^qClosedBit, getPos, putPos&qClosedBit != 0)
 }
 
 // GetCapacity 获取容量
-func (q *Queue) GetCapacity() uint64 {
+func (q *QueueOf[T]) GetCapacity() uint64 {
 	return q.capacity
 }
 
 // GetQuantity 获取当前队列剩余多少条记录
-func (q *Queue) GetQuantity() uint64 {
+func (q *QueueOf[T]) GetQuantity() uint64 {
 	quantity := uint64(0)
 	getPos := atomic.LoadUint64(&q.getPos)
-	putPos := atomic.LoadUint64(&q.putPos)
+	putPos := atomic.LoadUint64(&q.putPos) &^ qClosedBit
 	if putPos >= getPos { // 如果插入的位置比取出的位置大，那么数量就是插入位置减去取出位置就是剩余的数量了。
 		quantity = putPos - getPos
 	}
 	return quantity
 }
 
+// Close 关闭队列，之后的插入都会失败，取出可以继续进行直到队列为空，之后取出会返回ErrQueueClosed。
+// 重复关闭没有影响。
+func (q *QueueOf[T]) Close() {
+	for {
+		putPos := atomic.LoadUint64(&q.putPos)
+		if putPos&qClosedBit != 0 {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&q.putPos, putPos, putPos|qClosedBit) {
+			break
+		}
+	}
+	// 唤醒所有阻塞的协程，让它们返回ErrQueueClosed
+	q.notEmpty.broadcast()
+	q.notFull.broadcast()
+}
+
+// IsClosed 队列是否已经关闭
+func (q *QueueOf[T]) IsClosed() bool {
+	return atomic.LoadUint64(&q.putPos)&qClosedBit != 0
+}
+
+// GetBatch 批量取出记录，先阻塞直到取出第一条，然后在maxWait之内继续取出直到填满values，
+// 用于按照数量或者时间批量处理。返回取出的数量，至少取出一条时错误为nil，
+// 一条都没有取出时返回ctx的错误或者ErrQueueClosed。
+func (q *QueueOf[T]) GetBatch(ctx context.Context, values []T, maxWait time.Duration) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	n, err := q.GetsContext(ctx, values)
+	if err != nil {
+		return 0, err
+	}
+	if n < len(values) { // 不等待地再取一次，队列中已经有足够的记录时不需要创建定时器
+		cnt, _, _ := q.tryGets(values[n:])
+		n += cnt
+	}
+	if n == len(values) || maxWait <= 0 {
+		return n, nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+	for n < len(values) {
+		cnt, err := q.GetsContext(waitCtx, values[n:])
+		n += cnt
+		if err != nil { // 等待超时、ctx结束或者队列关闭，先返回已经取出的记录
+			break
+		}
+	}
+	return n, nil
+}
+
+// Chan 将队列转换成只读通道，队列关闭并且取完之后通道关闭。
+// 内部协程会提前取出一条记录等待发送，所以不再读取通道时需要关闭队列并读完通道，否则协程和这条记录会一直阻塞。
+func (q *QueueOf[T]) Chan() <-chan T {
+	ch := make(chan T)
+	go func() {
+		defer close(ch)
+		for {
+			value, err := q.GetContext(context.Background())
+			if err != nil {
+				return
+			}
+			ch <- value
+		}
+	}()
+	return ch
+}
+
+// All 返回一个迭代器，可以用for range阻塞地取出队列的记录，队列关闭并且取完之后结束。
+// 提前退出循环不会多取出记录。
+//
+//	for value := range q.All() {
+//		...
+//	}
+func (q *QueueOf[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			value, err := q.GetContext(context.Background())
+			if err != nil || !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// 一次尝试的结果
+const (
+	qSuccess   = 0 // 成功
+	qNoRoom    = 1 // 插入时队列已满，或者取出时队列为空
+	qContended = 2 // CAS竞争失败
+	qClosed    = 3 // 队列已经关闭，取出时队列已经为空
+)
+
 // Put 向队列插入数据，返回是否成功，剩余数量。
-func (q *Queue) Put(value interface{}) (bool, uint64) {
-	var putPos, newPutPos, getPos, posCnt uint64
-	var cache *qcache
-	getPos = atomic.LoadUint64(&q.getPos)
-	putPos = atomic.LoadUint64(&q.putPos)
-	// 计算剩余的pos，此pos就可以理解为队列内的一条记录
-	if putPos > getPos {
-		posCnt = putPos - getPos
-	} else {
-		posCnt = 0
-	}
-	if posCnt >= q.capacity { // 如果posCnt大于队列自身容量，说明已经满了。
-		time.Sleep(q.sleepTime) // 睡眠一段时间，正常是时间越小越好，但是也要看情况。
+func (q *QueueOf[T]) Put(value T) (bool, uint64) {
+	status, posCnt := q.tryPut(value)
+	if status != qSuccess {
+		atomic.AddUint64(&q.putFails, 1)
+		q.pause(status)
 		return false, posCnt
 	}
+	return true, posCnt
+}
+
+// Get 从队列中获取记录，返回取出的值，是否成功，剩余数量。
+func (q *QueueOf[T]) Get() (T, bool, uint64) {
+	value, status, posCnt := q.tryGet()
+	if status != qSuccess {
+		atomic.AddUint64(&q.getFails, 1)
+		q.pause(status)
+		return value, false, posCnt
+	}
+	return value, true, posCnt
+}
+
+// Puts 向队列插入多条数据，返回添加的记录数量，剩余数量。
+func (q *QueueOf[T]) Puts(values []T) (int, uint64) {
+	putCnt, status, posCnt := q.tryPuts(values)
+	if status != qSuccess {
+		atomic.AddUint64(&q.putFails, 1)
+		q.pause(status)
+	}
+	return putCnt, posCnt
+}
+
+// Gets 获取多条记录，返回获取的记录数量，剩余数量。
+func (q *QueueOf[T]) Gets(values []T) (int, uint64) {
+	getCnt, status, posCnt := q.tryGets(values)
+	if status != qSuccess {
+		atomic.AddUint64(&q.getFails, 1)
+		q.pause(status)
+	}
+	return getCnt, posCnt
+}
+
+// PutContext 向队列插入数据，队列已满时阻塞，直到插入成功或者ctx被取消、超时，
+// 取消时返回context.Canceled，超时返回context.DeadlineExceeded，队列关闭时返回ErrQueueClosed。
+func (q *QueueOf[T]) PutContext(ctx context.Context, value T) error {
+	return qblock(ctx, &q.notFull, func() int {
+		status, _ := q.tryPut(value)
+		return status
+	})
+}
+
+// GetContext 从队列中获取记录，队列为空时阻塞，直到取出成功或者ctx被取消、超时，
+// 队列关闭并且已经取完时返回ErrQueueClosed。
+func (q *QueueOf[T]) GetContext(ctx context.Context) (T, error) {
+	var value T
+	err := qblock(ctx, &q.notEmpty, func() int {
+		var status int
+		value, status, _ = q.tryGet()
+		return status
+	})
+	return value, err
+}
+
+// PutsContext 向队列插入多条数据，阻塞直到全部插入或者ctx被取消、超时、队列关闭，返回已插入的数量。
+func (q *QueueOf[T]) PutsContext(ctx context.Context, values []T) (int, error) {
+	total := 0
+	err := qblock(ctx, &q.notFull, func() int {
+		putCnt, status, _ := q.tryPuts(values[total:])
+		total += putCnt
+		if total < len(values) && status == qSuccess { // 只插入了一部分，说明队列满了
+			return qNoRoom
+		}
+		return status
+	})
+	return total, err
+}
 
-	newPutPos = putPos + 1 // 新的putPos
-	// 先比较变量的值是否等于给定旧值，等于旧值的情况下才赋予新值，最后返回新值是否设置成功。
-	if !atomic.CompareAndSwapUint64(&q.putPos, putPos, newPutPos) {
+// GetsContext 获取多条记录，队列为空时阻塞，直到至少取出一条或者ctx被取消、超时、队列关闭并且已经取完，返回取出的数量。
+func (q *QueueOf[T]) GetsContext(ctx context.Context, values []T) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	getCnt := 0
+	err := qblock(ctx, &q.notEmpty, func() int {
+		var status int
+		getCnt, status, _ = q.tryGets(values)
+		return status
+	})
+	return getCnt, err
+}
+
+// 尝试失败后的等待，队列满或空时睡眠，竞争失败时让出处理器，队列关闭时直接返回
+func (q *QueueOf[T]) pause(status int) {
+	switch status {
+	case qNoRoom:
+		time.Sleep(q.sleepTime) // 睡眠一段时间，正常是时间越小越好，但是也要看情况。
+	case qContended:
 		runtime.Gosched() // 处理器的时间间隙
-		return false, posCnt
 	}
-	// // newPutPos&q.capMod == newPutPos % q.capacity when q.capacity is 2^n
-	cache = &q.cache[newPutPos&q.capMod] // 这个步骤是在做取余操作，相当于分块。
-	for {                                // 无限循环
+}
+
+// 阻塞之前的尝试次数
+const queueSpins = 32
+
+// qblock 反复尝试，先退避自旋，队列满或空时挂起在通知器上，直到成功或者ctx结束
+func qblock(ctx context.Context, n *qnotifier, try func() int) error {
+	var bo qbackoff
+	for spins := 0; ; spins++ {
+		status := try()
+		if status == qSuccess {
+			return nil
+		}
+		if status == qClosed {
+			return ErrQueueClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if status == qContended || spins < queueSpins {
+			bo.pause()
+			continue
+		}
+		// 先登记再重试一次，避免在登记之前发生的通知丢失
+		ch := n.register()
+		status = try()
+		if status != qNoRoom {
+			n.unregister()
+			switch status {
+			case qSuccess:
+				return nil
+			case qClosed:
+				return ErrQueueClosed
+			}
+			continue
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+		n.unregister()
+		bo = 0
+	}
+}
+
+// CAS竞争失败时在try函数内部重试的次数，超过之后返回qContended
+const qCASRetries = 4
+
+// tryPut 尝试插入一条数据，不等待，返回结果和剩余数量。
+func (q *QueueOf[T]) tryPut(value T) (int, uint64) {
+	putCnt, status, pos, posCnt := q.reserve(1, true)
+	if putCnt == 0 {
+		return status, posCnt
+	}
+	q.putSlot(pos, value)
+	q.notEmpty.broadcast()
+	return qSuccess, posCnt
+}
+
+// tryGet 尝试取出一条记录，不等待，返回取出的值，结果，剩余数量。
+func (q *QueueOf[T]) tryGet() (T, int, uint64) {
+	var zero T
+	getCnt, status, pos, posCnt := q.reserve(1, false)
+	if getCnt == 0 {
+		return zero, status, posCnt
+	}
+	value := q.getSlot(pos)
+	q.notFull.broadcast()
+	return value, qSuccess, posCnt
+}
+
+// tryPuts 尝试插入多条数据，不等待，返回添加的记录数量，结果，剩余数量。
+func (q *QueueOf[T]) tryPuts(values []T) (int, int, uint64) {
+	if len(values) == 0 {
+		return 0, qSuccess, q.GetQuantity()
+	}
+	putCnt, status, pos, posCnt := q.reserve(uint64(len(values)), true)
+	if putCnt == 0 {
+		return 0, status, posCnt
+	}
+	for v := uint64(0); v < putCnt; v++ {
+		q.putSlot(pos+v, values[v])
+	}
+	q.notEmpty.broadcast()
+	return int(putCnt), qSuccess, posCnt
+}
+
+// tryGets 尝试获取多条记录，不等待，返回获取的记录数量，结果，剩余数量。
+func (q *QueueOf[T]) tryGets(values []T) (int, int, uint64) {
+	if len(values) == 0 {
+		return 0, qSuccess, q.GetQuantity()
+	}
+	getCnt, status, pos, posCnt := q.reserve(uint64(len(values)), false)
+	if getCnt == 0 {
+		return 0, status, posCnt
+	}
+	for v := uint64(0); v < getCnt; v++ {
+		values[v] = q.getSlot(pos + v)
+	}
+	q.notFull.broadcast()
+	return int(getCnt), qSuccess, posCnt
+}
+
+// reserve 通过CAS移动putPos（put为true）或者getPos，预定最多size个位置，竞争失败时有限次地退避重试。
+// 返回预定的数量、结果、第一个预定的位置和操作之后的剩余数量，失败时预定的数量为0。
+// 预定之后必须对每个位置调用putSlot或者getSlot。
+func (q *QueueOf[T]) reserve(size uint64, put bool) (uint64, int, uint64, uint64) {
+	var bo qbackoff
+	for retry := 0; ; retry++ {
+		var posCnt, cnt uint64
+		getPos := atomic.LoadUint64(&q.getPos)
+		putPos := atomic.LoadUint64(&q.putPos)
+		closed := putPos&qClosedBit != 0
+		if put && closed { // 队列已经关闭，不允许插入
+			return 0, qClosed, 0, q.GetQuantity()
+		}
+		putPos &^= qClosedBit
+		// 计算剩余的pos，此pos就可以理解为队列内的一条记录
+		if putPos > getPos {
+			posCnt = putPos - getPos
+		}
+		if put {
+			if posCnt >= q.capacity { // 如果posCnt大于队列自身容量，说明已经满了。
+				return 0, qNoRoom, 0, posCnt
+			}
+			cnt = q.capacity - posCnt
+		} else {
+			if posCnt < 1 { // 如果剩余小于1也就是等于0，那么失败返回。
+				if closed {
+					return 0, qClosed, 0, posCnt
+				}
+				return 0, qNoRoom, 0, posCnt
+			}
+			cnt = posCnt
+		}
+		if cnt > size {
+			cnt = size
+		}
+		// 先比较变量的值是否等于给定旧值，等于旧值的情况下才赋予新值，最后返回新值是否设置成功。
+		if put && atomic.CompareAndSwapUint64(&q.putPos, putPos, putPos+cnt) {
+			return cnt, qSuccess, putPos + 1, posCnt + cnt
+		}
+		if !put && atomic.CompareAndSwapUint64(&q.getPos, getPos, getPos+cnt) {
+			return cnt, qSuccess, getPos + 1, posCnt - cnt
+		}
+		atomic.AddUint64(&q.casRetries, 1)
+		if retry >= qCASRetries {
+			return 0, qContended, 0, posCnt
+		}
+		bo.pause()
+	}
+}
+
+// putSlot 将值写入已经预定的位置，等待这个位置上一轮的记录被取走
+func (q *QueueOf[T]) putSlot(pos uint64, value T) {
+	var bo qbackoff
+	// pos&q.capMod == pos % q.capacity when q.capacity is 2^n
+	cache := &q.cache[pos&q.capMod] // 这个步骤是在做取余操作，相当于分块。
+	for {
 		getNo := atomic.LoadUint64(&cache.getNo)
 		putNo := atomic.LoadUint64(&cache.putNo)
-		if newPutPos == putNo && getNo == putNo {
+		if pos == putNo && getNo == putNo {
 			cache.value = value                        // 将值写入队列
-			atomic.AddUint64(&cache.putNo, q.capacity) //将缓存内的putNo设置为队列容量
-			return true, posCnt + 1
+			atomic.AddUint64(&cache.putNo, q.capacity) // 将缓存内的putNo设置为下一轮的位置
+			return
 		}
-		runtime.Gosched()
+		bo.pause()
 	}
 }
 
-// Get 从队列中获取记录，返回取出的值，是否成功，剩余数量。
-func (q *Queue) Get() (interface{}, bool, uint64) {
-	var putPos, getPos, newGetPos, posCnt uint64
-	var cache *qcache
-	putPos = atomic.LoadUint64(&q.putPos)
-	getPos = atomic.LoadUint64(&q.getPos)
-	// 计算剩余的pos，此pos就可以理解为队列内的一条记录
-	if putPos > getPos {
-		posCnt = putPos - getPos
-	} else {
-		posCnt = 0
-	}
-	if posCnt < 1 { // 如果剩余小于1也就是等于0，那么失败返回。
-		time.Sleep(q.sleepTime)
-		return nil, false, posCnt
-	}
-	newGetPos = getPos + 1
-	// 先比较变量的值是否等于给定旧值，等于旧值的情况下才赋予新值，最后返回新值是否设置成功。
-	if !atomic.CompareAndSwapUint64(&q.getPos, getPos, newGetPos) {
-		runtime.Gosched()
-		return nil, false, posCnt
-	}
-
-	// putPosNew&q.capMod == putPosNew % q.capacity when q.capacity is 2 ^ n
-	cache = &q.cache[newGetPos&q.capMod]
+// getSlot 从已经预定的位置取出值，等待这个位置的记录写入完成
+func (q *QueueOf[T]) getSlot(pos uint64) T {
+	var bo qbackoff
+	var zero T
+	cache := &q.cache[pos&q.capMod]
 	for {
-		getNo := atomic.LoadUint64(&cache.getNo)
+		// 写入完成之后占用这个位置，和Peek互斥地读写值
 		putNo := atomic.LoadUint64(&cache.putNo)
-		if newGetPos == getNo && getNo == putNo-q.capacity {
-			value := cache.value                       // 取出值
-			cache.value = nil                          // 将原有值指向nil
-			atomic.AddUint64(&cache.getNo, q.capacity) // 设定缓存的getNo为队列的容量
-			return value, true, posCnt - 1
+		if putNo == pos+q.capacity && atomic.CompareAndSwapUint64(&cache.getNo, pos, pos|qSlotBusyBit) {
+			value := cache.value                             // 取出值
+			cache.value = zero                               // 将原有值置为零值，释放引用
+			atomic.StoreUint64(&cache.getNo, pos+q.capacity) // 设定缓存的getNo为下一轮的位置，同时解除占用
+			return value
 		}
-		runtime.Gosched()
+		bo.pause()
 	}
 }
 
-// Puts 向队列插入多条数据，返回添加的记录数量，剩余数量。
-func (q *Queue) Puts(values []interface{}) (int, uint64) {
-	var putPos, newPputPos, getPos, posCnt, putCnt uint64
-	getPos = atomic.LoadUint64(&q.getPos)
-	putPos = atomic.LoadUint64(&q.putPos)
-	if putPos > getPos { // 计算剩余的pos，此pos就可以理解为队列内的一条记录
-		posCnt = putPos - getPos
-	} else {
-		posCnt = 0
-	}
-	// 如果已经满了，就不能添加了。
-	if posCnt >= q.capacity {
-		time.Sleep(q.sleepTime)
-		return 0, posCnt
-	}
-	if capPuts, size := q.capacity-posCnt, uint64(len(values)); capPuts >= size {
-		putCnt = size
-	} else {
-		putCnt = capPuts
-	}
-	newPputPos = putPos + putCnt
-	if !atomic.CompareAndSwapUint64(&q.putPos, putPos, newPputPos) {
-		runtime.Gosched()
-		return 0, posCnt
-	}
-	for posNew, v := putPos+1, uint64(0); v < putCnt; posNew, v = posNew+1, v+1 {
-		// putPosNew&q.capMod == putPosNew % q.capacity when q.capacity is 2 ^ n
-		var cache = &q.cache[posNew&q.capMod]
-		for {
-			getNo := atomic.LoadUint64(&cache.getNo)
-			putNo := atomic.LoadUint64(&cache.putNo)
-			if posNew == putNo && getNo == putNo {
-				cache.value = values[v]
-				atomic.AddUint64(&cache.putNo, q.capacity)
-				break
-			} else {
-				runtime.Gosched()
+// Peek 查看队列头部的记录但不取出，队列为空时返回false。
+// 读取时和取出一样通过CAS短暂地占用位置上的getNo，不会和取出的协程同时读写值。
+// 头部的记录在读取期间被取出或者还没有写入完成时重试，直到读到头部的记录或者队列为空。
+func (q *QueueOf[T]) Peek() (T, bool) {
+	var bo qbackoff
+	for {
+		if values, ok := q.PeekN(1); ok {
+			if len(values) == 0 {
+				var zero T
+				return zero, false
 			}
+			return values[0], true
 		}
+		bo.pause()
 	}
-	return int(putCnt), posCnt + putCnt
 }
 
-// Gets 获取多条记录，返回获取的记录数量，剩余数量。
-func (q *Queue) Gets(values []interface{}) (int, uint64) {
-	var putPos, getPos, newGetPos, posCnt, getCnt uint64
-
-	putPos = atomic.LoadUint64(&q.putPos)
-	getPos = atomic.LoadUint64(&q.getPos)
+// PeekN 查看队列头部最多n条记录但不取出，不影响并发的插入和取出。
+// 返回的记录是同一时刻队列中的快照：复制期间有记录被取出时重试，
+// 取出竞争激烈、多次重试仍然失败时返回nil和false，不会返回不一致的部分记录。队列为空时返回空切片和true。
+func (q *QueueOf[T]) PeekN(n int) ([]T, bool) {
+	var bo qbackoff
+	var values []T
+	for retry := 0; retry <= qPeekRetries; retry++ {
+		getPos := atomic.LoadUint64(&q.getPos)
+		putPos := atomic.LoadUint64(&q.putPos) &^ qClosedBit
+		cnt := uint64(0)
+		if putPos > getPos {
+			cnt = putPos - getPos
+		}
+		if cnt > uint64(n) {
+			cnt = uint64(n)
+		}
+		values = values[:0]
+		for pos := getPos + 1; pos <= getPos+cnt; pos++ {
+			value, ok := q.peekSlot(pos)
+			if !ok {
+				break
+			}
+			values = append(values, value)
+		}
+		// 复制期间没有记录被取出，说明所有的记录在这一刻同时在队列中
+		if atomic.LoadUint64(&q.getPos) == getPos && uint64(len(values)) == cnt {
+			return values, true
+		}
+		bo.pause()
+	}
+	return nil, false
+}
 
-	if putPos > getPos {
-		posCnt = putPos - getPos
-	} else {
-		posCnt = 0
+// Snapshot 返回一个迭代器，遍历调用时队列中记录的副本，不影响并发的插入和取出。
+// 和PeekN一样，多次重试仍然得不到一致的快照时返回nil和false
+func (q *QueueOf[T]) Snapshot() (iter.Seq[T], bool) {
+	values, ok := q.PeekN(int(q.GetQuantity()))
+	if !ok {
+		return nil, false
 	}
+	return func(yield func(T) bool) {
+		for _, value := range values {
+			if !yield(value) {
+				return
+			}
+		}
+	}, true
+}
 
-	if posCnt < 1 {
-		time.Sleep(q.sleepTime)
-		return 0, posCnt
+// Dump 输出队列的状态、指标和头部最多n条记录（默认10条），用于调试
+func (q *QueueOf[T]) Dump(n ...int) string {
+	num := 10
+	if len(n) > 0 {
+		num = n[0]
+	}
+	var buf strings.Builder
+	buf.WriteString(q.ToString())
+	fmt.Fprintf(&buf, "\nquantity: %v, putFails: %v, getFails: %v, casRetries: %v, waiting: {get: %v, put: %v}",
+		q.GetQuantity(), atomic.LoadUint64(&q.putFails), atomic.LoadUint64(&q.getFails),
+		atomic.LoadUint64(&q.casRetries), atomic.LoadInt32(&q.notEmpty.waiters), atomic.LoadInt32(&q.notFull.waiters))
+	values, ok := q.PeekN(num)
+	if !ok {
+		buf.WriteString("\nhead: unavailable, items are being taken too fast")
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "\nhead (%d):", len(values))
+	for i, value := range values {
+		fmt.Fprintf(&buf, "\n  [%d] %+v", i, value)
 	}
+	return buf.String()
+}
+
+// PeekN复制期间有记录被取出时的重试次数，超过之后返回false
+const qPeekRetries = 16
 
-	if size := uint64(len(values)); posCnt >= size {
-		getCnt = size
-	} else {
-		getCnt = posCnt
+// qSlotBusyBit getNo的最高位，表示位置正在被取出或者查看，其他协程需要等待
+const qSlotBusyBit = uint64(1) << 63
+
+// peekSlot 读取已经写入并且还没有被取出的位置，写入还没有完成或者已经被取出时返回false。
+// 和getSlot一样先占用位置再读取值，读取期间这个位置不会被取出
+func (q *QueueOf[T]) peekSlot(pos uint64) (T, bool) {
+	var bo qbackoff
+	var zero T
+	cache := &q.cache[pos&q.capMod]
+	if atomic.LoadUint64(&cache.putNo) != pos+q.capacity {
+		return zero, false
+	}
+	for {
+		switch getNo := atomic.LoadUint64(&cache.getNo); getNo {
+		case pos:
+			if atomic.CompareAndSwapUint64(&cache.getNo, pos, pos|qSlotBusyBit) {
+				value := cache.value
+				atomic.StoreUint64(&cache.getNo, pos) // 解除占用
+				return value, true
+			}
+		case pos | qSlotBusyBit: // 正在被取出或者被其他协程查看，等待之后再判断
+		default: // 已经被取出
+			return zero, false
+		}
+		bo.pause()
 	}
-	newGetPos = getPos + getCnt
+}
 
-	if !atomic.CompareAndSwapUint64(&q.getPos, getPos, newGetPos) {
-		runtime.Gosched()
-		return 0, posCnt
+// Collect 收集队列的指标，实现Collector接口，多个队列可以通过WithLabels区分
+func (q *QueueOf[T]) Collect() []Metric {
+	return []Metric{
+		{Name: "lodago_queue_capacity", Help: "Capacity of the queue.", Type: GaugeMetric,
+			Samples: []MetricSample{{Value: float64(q.GetCapacity())}}},
+		{Name: "lodago_queue_quantity", Help: "Number of items in the queue.", Type: GaugeMetric,
+			Samples: []MetricSample{{Value: float64(q.GetQuantity())}}},
+		{Name: "lodago_queue_put_failures_total", Help: "Number of failed puts, because the queue is full or contended.", Type: CounterMetric,
+			Samples: []MetricSample{{Value: float64(atomic.LoadUint64(&q.putFails))}}},
+		{Name: "lodago_queue_get_failures_total", Help: "Number of failed gets, because the queue is empty or contended.", Type: CounterMetric,
+			Samples: []MetricSample{{Value: float64(atomic.LoadUint64(&q.getFails))}}},
+		{Name: "lodago_queue_cas_retries_total", Help: "Number of lost compare-and-swap races on the queue positions.", Type: CounterMetric,
+			Samples: []MetricSample{{Value: float64(atomic.LoadUint64(&q.casRetries))}}},
 	}
+}
 
-	for posNew, v := getPos+1, uint64(0); v < getCnt; posNew, v = posNew+1, v+1 {
-		// putPosNew&q.capMod == putPosNew % q.capacity when q.capacity is 2 ^ n
-		var cache = &q.cache[posNew&q.capMod]
-		for {
-			getNo := atomic.LoadUint64(&cache.getNo)
-			putNo := atomic.LoadUint64(&cache.putNo)
-			if posNew == getNo && getNo == putNo-q.capacity {
-				values[v] = cache.value
-				cache.value = nil
-				getNo = atomic.AddUint64(&cache.getNo, q.capacity)
-				break
-			} else {
-				runtime.Gosched()
-			}
+// 退避时忙等的轮数，每轮忙等的次数翻倍，之后让出处理器
+const qSpinLimit = 6
+
+// qbackoff 有界的退避，先短暂忙等，之后让出处理器，避免竞争时所有协程都立即调度让出
+type qbackoff uint32
+
+// pause 退避一次
+func (b *qbackoff) pause() {
+	if *b < qSpinLimit {
+		for i := 0; i < 1<<*b; i++ {
+			qspin()
 		}
+		*b++
+		return
 	}
+	runtime.Gosched()
+}
+
+// qspin 忙等一次，不能被编译器优化掉
+//
+//go:noinline
+func qspin() {}
 
-	return int(getCnt), posCnt - getCnt
+// qnotifier 通知器，没有等待者时通知只需要一次原子读取
+type qnotifier struct {
+	waiters int32
+	locker  sync.Mutex
+	ch      chan struct{}
+}
+
+// register 登记一个等待者，返回在下一次通知时关闭的通道
+func (n *qnotifier) register() <-chan struct{} {
+	atomic.AddInt32(&n.waiters, 1)
+	n.locker.Lock()
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	ch := n.ch
+	n.locker.Unlock()
+	return ch
+}
+
+// unregister 取消登记
+func (n *qnotifier) unregister() {
+	atomic.AddInt32(&n.waiters, -1)
+}
+
+// broadcast 唤醒所有的等待者
+func (n *qnotifier) broadcast() {
+	if atomic.LoadInt32(&n.waiters) == 0 {
+		return
+	}
+	n.locker.Lock()
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
+	n.locker.Unlock()
 }
 
 // minQuantity 将传入的值转换成2的次方，遵循最小原则，例如：2->2，4->4，7->8,9->16
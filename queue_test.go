@@ -0,0 +1,628 @@
+package lodago
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// 基准测试使用的值类型，放进Queue时需要装箱分配内存
+type queueBenchItem struct {
+	id    int64
+	value int64
+}
+
+// 每次插入之后立即取出，队列不会满，只比较插入和取出本身的开销
+func BenchmarkQueuePut(b *testing.B) {
+	q := NewQueue(1024, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		q.Put(queueBenchItem{id: int64(i), value: int64(i)})
+		q.Get()
+	}
+}
+
+func BenchmarkQueueOfPut(b *testing.B) {
+	q := NewQueueOf[queueBenchItem](1024, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		q.Put(queueBenchItem{id: int64(i), value: int64(i)})
+		q.Get()
+	}
+}
+
+// 相邻位置的任意两个字节之间至少间隔一个缓存行
+func TestQueueSlotPadding(t *testing.T) {
+	check := func(name string, size, valueEnd uintptr) {
+		// 位置i的值的最后一个字节和位置i+1的第一个字节之间的距离
+		if gap := size - (valueEnd - 1); gap <= cacheLinePad {
+			t.Errorf("%s: gap between slots is %d bytes, want > %d", name, gap, cacheLinePad)
+		}
+	}
+	var iface qcache[interface{}]
+	check("interface{}", unsafe.Sizeof(iface), unsafe.Offsetof(iface.value)+unsafe.Sizeof(iface.value))
+	var item qcache[queueBenchItem]
+	check("struct", unsafe.Sizeof(item), unsafe.Offsetof(item.value)+unsafe.Sizeof(item.value))
+	var b qcache[byte]
+	check("byte", unsafe.Sizeof(b), unsafe.Offsetof(b.value)+unsafe.Sizeof(b.value))
+	// NewQueueOf文档中的内存占用：值之外是16字节的序号和一个缓存行的填充
+	var n qcache[int]
+	if overhead := unsafe.Sizeof(n) - unsafe.Sizeof(n.value); overhead != 16+cacheLinePad {
+		t.Errorf("int slot overhead is %d bytes, want %d", overhead, 16+cacheLinePad)
+	}
+}
+
+// 生产者和消费者的数量组合
+var queueBenchShapes = []struct{ producers, consumers int }{
+	{1, 1}, {4, 1}, {1, 4}, {4, 4}, {16, 16},
+}
+
+// runQueueBench 把b.N条记录平均分给生产者插入、消费者取出，put和get都是阻塞的
+func runQueueBench(b *testing.B, producers, consumers int, put func(int), get func()) {
+	var wg sync.WaitGroup
+	share := func(parts, i int) int {
+		if i < b.N%parts {
+			return b.N/parts + 1
+		}
+		return b.N / parts
+	}
+	b.ResetTimer()
+	for c := 0; c < consumers; c++ {
+		count := share(consumers, c)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				get()
+			}
+		}()
+	}
+	for p := 0; p < producers; p++ {
+		count := share(producers, p)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				put(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// 和带缓冲的channel比较，不同的生产者和消费者数量
+func BenchmarkQueueVsChannel(b *testing.B) {
+	ctx := context.Background()
+	for _, shape := range queueBenchShapes {
+		name := fmt.Sprintf("p%d_c%d", shape.producers, shape.consumers)
+		b.Run("QueueOf/"+name, func(b *testing.B) {
+			q := NewQueueOf[int](1024, 0)
+			runQueueBench(b, shape.producers, shape.consumers,
+				func(v int) { q.PutContext(ctx, v) },
+				func() { q.GetContext(ctx) })
+		})
+		b.Run("Chan/"+name, func(b *testing.B) {
+			ch := make(chan int, 1024)
+			runQueueBench(b, shape.producers, shape.consumers,
+				func(v int) { ch <- v },
+				func() { <-ch })
+		})
+	}
+}
+
+// stressQueuer producers个协程并发插入，一个协程取出，检查每个值恰好取出一次。
+// 交替使用单条和批量的插入取出，容量较小时会多次绕回
+func stressQueuer(t *testing.T, q Queuer[int], producers, perProducer int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(base int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; {
+				if i%2 == 0 {
+					if ok, _ := q.Put(base + i); ok {
+						i++
+					}
+					continue
+				}
+				batch := make([]int, 0, 8)
+				for j := i; j < perProducer && len(batch) < cap(batch); j++ {
+					batch = append(batch, base+j)
+				}
+				n, _ := q.Puts(batch)
+				i += n
+			}
+		}(p * perProducer)
+	}
+	total := producers * perProducer
+	seen := make([]int, total)
+	buf := make([]int, 8)
+	for received := 0; received < total; {
+		if received%2 == 0 {
+			if v, ok, _ := q.Get(); ok {
+				seen[v]++
+				received++
+			}
+			continue
+		}
+		n, _ := q.Gets(buf)
+		for _, v := range buf[:n] {
+			seen[v]++
+		}
+		received += n
+	}
+	wg.Wait()
+	for v, count := range seen {
+		if count != 1 {
+			t.Fatalf("value %d received %d times", v, count)
+		}
+	}
+	if quantity := q.GetQuantity(); quantity != 0 {
+		t.Fatalf("quantity = %d after draining, want 0", quantity)
+	}
+}
+
+// stressCount 压力测试每个生产者插入的数量，-short时减少
+func stressCount() int {
+	if testing.Short() {
+		return 2000
+	}
+	return 20000
+}
+
+// Peek和并发的插入取出一起运行，用-race检查没有数据竞争，查看到的记录是按顺序的并且是完整的
+func TestQueuePeekConcurrent(t *testing.T) {
+	type item struct{ a, b int }
+	q := NewQueueOf[item](64, time.Microsecond)
+	total := stressCount()
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < total; i++ {
+			q.PutContext(ctx, item{i, -i})
+		}
+	}()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			runtime.Gosched()
+			values, ok := q.PeekN(8)
+			if !ok && values != nil {
+				t.Errorf("PeekN failed with partial values %+v", values)
+				return
+			}
+			for i, v := range values {
+				if v.b != -v.a {
+					t.Errorf("torn value %+v", v)
+					return
+				}
+				if i > 0 && v.a != values[i-1].a+1 {
+					t.Errorf("peeked values out of order: %+v", values)
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < total; i++ {
+		v, err := q.GetContext(ctx)
+		if err != nil || v.a != i {
+			t.Fatalf("GetContext = %+v, %v, want %d", v, err, i)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	<-done
+	if _, ok := q.Peek(); ok {
+		t.Fatal("Peek on empty queue returned a value")
+	}
+}
+
+// waitForWaiters 等待n上至少有一个挂起的协程，用于确认协程已经阻塞
+func waitForWaiters(t *testing.T, n *qnotifier) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&n.waiters) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("goroutine did not block")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := NewQueueOf[int](4, time.Microsecond)
+	q.Put(1)
+	q.Put(2)
+	if q.IsClosed() {
+		t.Fatal("new queue is closed")
+	}
+	q.Close()
+	q.Close() // 重复关闭没有影响
+	if !q.IsClosed() {
+		t.Fatal("IsClosed = false after Close")
+	}
+	if ok, _ := q.Put(3); ok {
+		t.Fatal("Put succeeded after Close")
+	}
+	if err := q.PutContext(context.Background(), 3); err != ErrQueueClosed {
+		t.Fatalf("PutContext = %v, want ErrQueueClosed", err)
+	}
+	// 关闭之前插入的记录仍然可以取出
+	for _, want := range []int{1, 2} {
+		if v, err := q.GetContext(context.Background()); err != nil || v != want {
+			t.Fatalf("GetContext = %d, %v, want %d", v, err, want)
+		}
+	}
+	if _, err := q.GetContext(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("GetContext on drained queue = %v, want ErrQueueClosed", err)
+	}
+	if _, ok, _ := q.Get(); ok {
+		t.Fatal("Get succeeded on a closed and drained queue")
+	}
+}
+
+// 阻塞的插入和取出在关闭时被唤醒并返回ErrQueueClosed
+func TestQueueCloseWakesBlocked(t *testing.T) {
+	ctx := context.Background()
+	full := NewQueueOf[int](2, time.Microsecond)
+	full.Puts([]int{1, 2})
+	empty := NewQueueOf[int](2, time.Microsecond)
+	putErr, getErr := make(chan error), make(chan error)
+	go func() { putErr <- full.PutContext(ctx, 3) }()
+	go func() {
+		_, err := empty.GetContext(ctx)
+		getErr <- err
+	}()
+	waitForWaiters(t, &full.notFull)
+	waitForWaiters(t, &empty.notEmpty)
+	full.Close()
+	empty.Close()
+	for name, ch := range map[string]chan error{"PutContext": putErr, "GetContext": getErr} {
+		select {
+		case err := <-ch:
+			if err != ErrQueueClosed {
+				t.Errorf("%s = %v, want ErrQueueClosed", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not woken by Close", name)
+		}
+	}
+	if full.GetQuantity() != 2 {
+		t.Fatalf("quantity = %d, want 2", full.GetQuantity())
+	}
+}
+
+// 通道在队列关闭并且取完之后关闭
+func TestQueueChan(t *testing.T) {
+	q := NewQueueOf[int](8, time.Microsecond)
+	ch := q.Chan()
+	q.Puts([]int{1, 2, 3})
+	if v := <-ch; v != 1 {
+		t.Fatalf("first value = %d, want 1", v)
+	}
+	q.Close()
+	var got []int
+	for v := range ch {
+		got = append(got, v)
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("values after Close = %v, want %v", got, want)
+	}
+}
+
+func TestQueueAll(t *testing.T) {
+	q := NewQueueOf[int](8, time.Microsecond)
+	q.Puts([]int{1, 2, 3, 4, 5})
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+		if v == 2 {
+			break
+		}
+	}
+	// 提前退出不会多取出记录
+	if q.GetQuantity() != 3 {
+		t.Fatalf("quantity after break = %d, want 3", q.GetQuantity())
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for v := range q.All() {
+			got = append(got, v)
+		}
+	}()
+	waitForWaiters(t, &q.notEmpty) // 取完之后阻塞等待，直到关闭
+	q.Close()
+	<-done
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("All = %v, want %v", got, want)
+	}
+}
+
+func TestQueuePeekN(t *testing.T) {
+	q := NewQueueOf[int](8, time.Microsecond)
+	if values, ok := q.PeekN(4); !ok || len(values) != 0 {
+		t.Fatalf("PeekN on empty queue = %v, %v", values, ok)
+	}
+	q.Puts([]int{1, 2, 3})
+	if values, ok := q.PeekN(2); !ok || !reflect.DeepEqual(values, []int{1, 2}) {
+		t.Fatalf("PeekN(2) = %v, %v", values, ok)
+	}
+	items, ok := q.Snapshot()
+	if !ok {
+		t.Fatal("Snapshot failed on a quiet queue")
+	}
+	var got []int
+	for v := range items {
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) || q.GetQuantity() != 3 {
+		t.Fatalf("Snapshot = %v, quantity %d", got, q.GetQuantity())
+	}
+	// 预定了位置但是还没有写入，得不到一致的快照时返回false而不是部分记录
+	_, _, pos, _ := q.reserve(1, true)
+	if values, ok := q.PeekN(8); ok || values != nil {
+		t.Fatalf("PeekN with a slot being written = %v, %v, want nil, false", values, ok)
+	}
+	if _, ok := q.Snapshot(); ok {
+		t.Fatal("Snapshot with a slot being written succeeded")
+	}
+	q.putSlot(pos, 4)
+	if values, ok := q.PeekN(8); !ok || !reflect.DeepEqual(values, []int{1, 2, 3, 4}) {
+		t.Fatalf("PeekN after the write = %v, %v", values, ok)
+	}
+	if v, ok := q.Peek(); !ok || v != 1 {
+		t.Fatalf("Peek = %d, %v, want 1", v, ok)
+	}
+}
+
+// 队列满时PutContext阻塞，取出一条之后插入成功
+func TestQueuePutContextBlocksUntilSpace(t *testing.T) {
+	q := NewQueueOf[int](2, time.Microsecond)
+	q.Puts([]int{1, 2})
+	done := make(chan error)
+	go func() { done <- q.PutContext(context.Background(), 3) }()
+	waitForWaiters(t, &q.notFull)
+	select {
+	case err := <-done:
+		t.Fatalf("PutContext returned %v on a full queue", err)
+	default:
+	}
+	if v, ok, _ := q.Get(); !ok || v != 1 {
+		t.Fatalf("Get = %d, %v", v, ok)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PutContext was not woken by Get")
+	}
+	for _, want := range []int{2, 3} {
+		if v, ok, _ := q.Get(); !ok || v != want {
+			t.Fatalf("Get = %d, %v, want %d", v, ok, want)
+		}
+	}
+}
+
+// 队列为空时GetContext阻塞，插入之后取出
+func TestQueueGetContextBlocksUntilData(t *testing.T) {
+	q := NewQueueOf[int](2, time.Microsecond)
+	type result struct {
+		value int
+		err   error
+	}
+	done := make(chan result)
+	go func() {
+		v, err := q.GetContext(context.Background())
+		done <- result{v, err}
+	}()
+	waitForWaiters(t, &q.notEmpty)
+	select {
+	case r := <-done:
+		t.Fatalf("GetContext returned %v on an empty queue", r)
+	default:
+	}
+	q.Put(7)
+	select {
+	case r := <-done:
+		if r.err != nil || r.value != 7 {
+			t.Fatalf("GetContext = %d, %v, want 7", r.value, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetContext was not woken by Put")
+	}
+}
+
+// ctx超时或者取消时返回ctx的错误，队列的内容不变
+func TestQueueContextDeadlineAndCancel(t *testing.T) {
+	full := NewQueueOf[int](2, time.Microsecond)
+	full.Puts([]int{1, 2})
+	empty := NewQueueOf[int](2, time.Microsecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := full.PutContext(ctx, 3); err != context.DeadlineExceeded {
+		t.Fatalf("PutContext = %v, want DeadlineExceeded", err)
+	}
+	if _, err := empty.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext = %v, want DeadlineExceeded", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	putErr, getErr := make(chan error), make(chan error)
+	go func() { putErr <- full.PutContext(ctx, 3) }()
+	go func() {
+		_, err := empty.GetContext(ctx)
+		getErr <- err
+	}()
+	waitForWaiters(t, &full.notFull)
+	waitForWaiters(t, &empty.notEmpty)
+	cancel()
+	for name, ch := range map[string]chan error{"PutContext": putErr, "GetContext": getErr} {
+		select {
+		case err := <-ch:
+			if err != context.Canceled {
+				t.Errorf("%s = %v, want Canceled", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not woken by cancel", name)
+		}
+	}
+	if full.GetQuantity() != 2 || empty.GetQuantity() != 0 {
+		t.Fatalf("quantity = %d, %d, want 2, 0", full.GetQuantity(), empty.GetQuantity())
+	}
+	if atomic.LoadInt32(&full.notFull.waiters) != 0 || atomic.LoadInt32(&empty.notEmpty.waiters) != 0 {
+		t.Fatal("canceled waiters are still registered")
+	}
+
+	// 已经结束的ctx不影响可以立即完成的操作
+	if v, err := full.GetContext(ctx); err != nil || v != 1 {
+		t.Fatalf("GetContext with canceled ctx = %d, %v, want 1", v, err)
+	}
+	if err := full.PutContext(ctx, 3); err != nil {
+		t.Fatalf("PutContext with canceled ctx = %v", err)
+	}
+	if err := full.PutContext(ctx, 4); err != context.Canceled {
+		t.Fatalf("PutContext on full queue with canceled ctx = %v, want Canceled", err)
+	}
+}
+
+// 队列关闭之后PutContext返回ErrQueueClosed，关闭优先于ctx的错误
+func TestQueueContextClosed(t *testing.T) {
+	q := NewQueueOf[int](2, time.Microsecond)
+	q.Put(1)
+	q.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := q.PutContext(ctx, 2); err != ErrQueueClosed {
+		t.Fatalf("PutContext = %v, want ErrQueueClosed", err)
+	}
+	if v, err := q.GetContext(ctx); err != nil || v != 1 {
+		t.Fatalf("GetContext = %d, %v, want 1", v, err)
+	}
+	if _, err := q.GetContext(ctx); err != ErrQueueClosed {
+		t.Fatalf("GetContext on drained queue = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueueGetBatch(t *testing.T) {
+	ctx := context.Background()
+	values := make([]int, 4)
+
+	// 队列中已经有足够的记录时立即返回，不等待maxWait
+	q := NewQueueOf[int](8, time.Microsecond)
+	q.Puts([]int{1, 2, 3, 4, 5})
+	start := time.Now()
+	if n, err := q.GetBatch(ctx, values, time.Hour); n != 4 || err != nil || !reflect.DeepEqual(values, []int{1, 2, 3, 4}) {
+		t.Fatalf("full batch = %d, %v, %v", n, err, values)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("full batch waited %v", elapsed)
+	}
+
+	// 不够一批时等到maxWait，返回已经取出的部分
+	q.Put(6)
+	start = time.Now()
+	if n, err := q.GetBatch(ctx, values, 30*time.Millisecond); n != 2 || err != nil || !reflect.DeepEqual(values[:n], []int{5, 6}) {
+		t.Fatalf("partial batch = %d, %v, %v", n, err, values[:n])
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("partial batch returned after %v, before maxWait", elapsed)
+	}
+
+	// maxWait之内插入的记录也会被取出，填满之后不再等待
+	q.Put(7)
+	done := make(chan int)
+	go func() {
+		n, _ := q.GetBatch(ctx, values, time.Hour)
+		done <- n
+	}()
+	waitForWaiters(t, &q.notEmpty)
+	q.Puts([]int{8, 9, 10})
+	select {
+	case n := <-done:
+		if n != 4 || !reflect.DeepEqual(values, []int{7, 8, 9, 10}) {
+			t.Fatalf("batch = %d, %v", n, values)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetBatch did not return after the batch was filled")
+	}
+
+	if n, err := q.GetBatch(ctx, nil, time.Hour); n != 0 || err != nil {
+		t.Fatalf("empty values = %d, %v", n, err)
+	}
+}
+
+func TestQueueGetBatchErrors(t *testing.T) {
+	values := make([]int, 4)
+	q := NewQueueOf[int](8, time.Microsecond)
+
+	// 一条都没有取出时返回ctx的错误
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if n, err := q.GetBatch(ctx, values, time.Hour); n != 0 || err != context.DeadlineExceeded {
+		t.Fatalf("GetBatch = %d, %v, want DeadlineExceeded", n, err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := q.GetBatch(ctx, values, time.Hour)
+		done <- err
+	}()
+	waitForWaiters(t, &q.notEmpty)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("GetBatch = %v, want Canceled", err)
+	}
+
+	// 等待maxWait的过程中ctx结束，返回已经取出的记录
+	q.Put(1)
+	ctx, cancel = context.WithCancel(context.Background())
+	type result struct {
+		n   int
+		err error
+	}
+	partial := make(chan result)
+	go func() {
+		n, err := q.GetBatch(ctx, values, time.Hour)
+		partial <- result{n, err}
+	}()
+	waitForWaiters(t, &q.notEmpty)
+	cancel()
+	if r := <-partial; r.n != 1 || r.err != nil || values[0] != 1 {
+		t.Fatalf("canceled during maxWait = %d, %v", r.n, r.err)
+	}
+
+	// 队列关闭时不再等待maxWait，取完之后返回ErrQueueClosed
+	q.Puts([]int{2, 3})
+	go func() {
+		n, err := q.GetBatch(context.Background(), values, time.Hour)
+		partial <- result{n, err}
+	}()
+	waitForWaiters(t, &q.notEmpty)
+	q.Close()
+	if r := <-partial; r.n != 2 || r.err != nil || !reflect.DeepEqual(values[:2], []int{2, 3}) {
+		t.Fatalf("closed during maxWait = %d, %v, %v", r.n, r.err, values[:r.n])
+	}
+	if n, err := q.GetBatch(context.Background(), values, time.Hour); n != 0 || err != ErrQueueClosed {
+		t.Fatalf("GetBatch on closed queue = %d, %v, want ErrQueueClosed", n, err)
+	}
+}
@@ -0,0 +1,134 @@
+package lodago
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 覆盖模式的环形队列，队列已满时丢弃最旧的记录给新记录腾出位置，插入永远不会因为队列已满而失败，
+// 适合只关心最新数据的场景，例如遥测数据。
+
+// RingQueue 元素类型为interface{}的覆盖模式环形队列
+type RingQueue = RingQueueOf[interface{}]
+
+// RingQueueOf 元素类型为T的覆盖模式环形队列，除了插入之外和QueueOf相同
+type RingQueueOf[T any] struct {
+	*QueueOf[T]
+	drops  uint64  // 被丢弃的记录数量
+	onDrop func(T) // 记录被丢弃时的回调
+}
+
+var _ Queuer[int] = (*RingQueueOf[int])(nil)
+
+// NewRingQueue 创建一个覆盖模式的环形队列，onDrop为可选的丢弃回调，在插入的协程中调用
+func NewRingQueue(capacity uint64, sleepTime time.Duration, onDrop ...func(interface{})) *RingQueue {
+	return NewRingQueueOf[interface{}](capacity, sleepTime, onDrop...)
+}
+
+// NewRingQueueOf 创建一个元素类型为T的覆盖模式环形队列
+func NewRingQueueOf[T any](capacity uint64, sleepTime time.Duration, onDrop ...func(T)) *RingQueueOf[T] {
+	q := &RingQueueOf[T]{QueueOf: NewQueueOf[T](capacity, sleepTime)}
+	if len(onDrop) > 0 {
+		q.onDrop = onDrop[0]
+	}
+	return q
+}
+
+// ToString 序列化成字符串
+func (q *RingQueueOf[T]) ToString() string {
+	getPos := atomic.LoadUint64(&q.getPos)
+	putPos := atomic.LoadUint64(&q.putPos)
+	return fmt.Sprintf("RingQueue{capacity: %v, capMod: %v, putPos: %v, getPos: %v, drops: %v, closed: %v}",
+		q.capacity, q.capMod, putPos&^qClosedBit, getPos, q.Drops(), putPos&qClosedBit != 0)
+}
+
+// Drops 被丢弃的记录数量
+func (q *RingQueueOf[T]) Drops() uint64 {
+	return atomic.LoadUint64(&q.drops)
+}
+
+// Put 向队列插入数据，队列已满时丢弃最旧的记录，只有队列关闭时失败，返回是否成功，剩余数量。
+func (q *RingQueueOf[T]) Put(value T) (bool, uint64) {
+	var bo qbackoff
+	for {
+		status, posCnt := q.tryPut(value)
+		switch status {
+		case qSuccess:
+			return true, posCnt
+		case qClosed:
+			atomic.AddUint64(&q.putFails, 1)
+			return false, posCnt
+		case qNoRoom:
+			q.dropOldest()
+		case qContended:
+			bo.pause()
+		}
+	}
+}
+
+// Puts 向队列插入多条数据，队列已满时丢弃最旧的记录，返回添加的记录数量，剩余数量。
+// 插入的数量超过容量时，前面插入的记录也会被丢弃。
+func (q *RingQueueOf[T]) Puts(values []T) (int, uint64) {
+	var bo qbackoff
+	total := 0
+	posCnt := q.GetQuantity()
+	for total < len(values) {
+		putCnt, status, cnt := q.tryPuts(values[total:])
+		total += putCnt
+		posCnt = cnt
+		switch status {
+		case qClosed:
+			atomic.AddUint64(&q.putFails, 1)
+			return total, posCnt
+		case qNoRoom:
+			q.dropOldest()
+		case qContended:
+			bo.pause()
+		}
+	}
+	return total, posCnt
+}
+
+// PutContext 向队列插入数据，不会因为队列已满而阻塞，队列关闭时返回ErrQueueClosed
+func (q *RingQueueOf[T]) PutContext(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ok, _ := q.Put(value); !ok {
+		return ErrQueueClosed
+	}
+	return nil
+}
+
+// PutsContext 向队列插入多条数据，不会因为队列已满而阻塞，队列关闭时返回ErrQueueClosed和已插入的数量
+func (q *RingQueueOf[T]) PutsContext(ctx context.Context, values []T) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if putCnt, _ := q.Puts(values); putCnt < len(values) {
+		return putCnt, ErrQueueClosed
+	}
+	return len(values), nil
+}
+
+// dropOldest 取出最旧的一条记录并丢弃，同时可能有消费者在取出，取不到时说明已经有了空位
+func (q *RingQueueOf[T]) dropOldest() {
+	value, status, _ := q.tryGet()
+	if status != qSuccess {
+		return
+	}
+	atomic.AddUint64(&q.drops, 1)
+	if q.onDrop != nil {
+		q.onDrop(value)
+	}
+}
+
+// Collect 收集队列的指标，在QueueOf的指标之外增加被丢弃的记录数量
+func (q *RingQueueOf[T]) Collect() []Metric {
+	return append(q.QueueOf.Collect(), Metric{
+		Name: "lodago_queue_dropped_total", Help: "Number of oldest items dropped to make room for new ones.", Type: CounterMetric,
+		Samples: []MetricSample{{Value: float64(q.Drops())}},
+	})
+}
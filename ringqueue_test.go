@@ -0,0 +1,96 @@
+package lodago
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// drainInts 不阻塞地取出队列中所有的记录
+func drainInts(q Queuer[int]) []int {
+	var values []int
+	for {
+		v, ok, _ := q.Get()
+		if !ok {
+			return values
+		}
+		values = append(values, v)
+	}
+}
+
+func TestRingQueueOverwriteOldest(t *testing.T) {
+	var dropped []int
+	q := NewRingQueueOf[int](4, time.Microsecond, func(v int) { dropped = append(dropped, v) })
+	for i := 1; i <= 6; i++ {
+		if ok, _ := q.Put(i); !ok {
+			t.Fatalf("Put(%d) failed", i)
+		}
+	}
+	if q.GetQuantity() != 4 || q.Drops() != 2 || !reflect.DeepEqual(dropped, []int{1, 2}) {
+		t.Fatalf("quantity %d, drops %d, dropped %v", q.GetQuantity(), q.Drops(), dropped)
+	}
+	if got := drainInts(q); !reflect.DeepEqual(got, []int{3, 4, 5, 6}) {
+		t.Fatalf("values = %v, want [3 4 5 6]", got)
+	}
+
+	// 一次插入超过容量的记录，前面插入的记录也会被丢弃
+	dropped = nil
+	q.Put(0)
+	if n, _ := q.Puts([]int{1, 2, 3, 4, 5, 6}); n != 6 {
+		t.Fatalf("Puts = %d, want 6", n)
+	}
+	if !reflect.DeepEqual(dropped, []int{0, 1, 2}) {
+		t.Fatalf("dropped = %v, want [0 1 2]", dropped)
+	}
+	if got := drainInts(q); !reflect.DeepEqual(got, []int{3, 4, 5, 6}) {
+		t.Fatalf("values = %v, want [3 4 5 6]", got)
+	}
+	if v, _ := findSample(q.Collect(), "lodago_queue_dropped_total", nil); v != 5 {
+		t.Fatalf("dropped_total = %v, want 5", v)
+	}
+
+	// PutContext不会因为队列已满而阻塞
+	for i := 0; i < 8; i++ {
+		if err := q.PutContext(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.GetQuantity() != 4 {
+		t.Fatalf("quantity = %d, want 4", q.GetQuantity())
+	}
+}
+
+func TestRingQueueClose(t *testing.T) {
+	var dropped []int
+	q := NewRingQueueOf[int](4, time.Microsecond, func(v int) { dropped = append(dropped, v) })
+	q.Puts([]int{1, 2})
+	q.Close()
+	if ok, _ := q.Put(3); ok {
+		t.Fatal("Put succeeded after Close")
+	}
+	if n, _ := q.Puts([]int{3, 4}); n != 0 {
+		t.Fatalf("Puts after Close = %d, want 0", n)
+	}
+	if err := q.PutContext(context.Background(), 3); err != ErrQueueClosed {
+		t.Fatalf("PutContext = %v, want ErrQueueClosed", err)
+	}
+	if n, err := q.PutsContext(context.Background(), []int{3}); n != 0 || err != ErrQueueClosed {
+		t.Fatalf("PutsContext = %d, %v, want ErrQueueClosed", n, err)
+	}
+	if len(dropped) != 0 {
+		t.Fatalf("records dropped by a failed put: %v", dropped)
+	}
+	if got := drainInts(q); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("values after Close = %v, want [1 2]", got)
+	}
+	if _, err := q.GetContext(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("GetContext = %v, want ErrQueueClosed", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRingQueueOf[int](4, 0).PutContext(ctx, 1); err != context.Canceled {
+		t.Fatalf("PutContext with cancelled ctx = %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package lodago
+
+import (
+	"iter"
+	"slices"
+)
+
+// key对应的values是一个集合，重复插入相同的value只保留一条，values按照第一次插入的顺序排列。
+
+// 一个key的values集合，index记录value在values中的位置
+type multimapValueSet[V comparable] struct {
+	values []V
+	index  map[V]int
+}
+
+// SetMultimap 每个key对应的values不重复的multimap
+type SetMultimap[K comparable, V comparable] struct {
+	m    map[K]*multimapValueSet[V]
+	size int
+}
+
+var _ Multimapper[string, int] = (*SetMultimap[string, int])(nil)
+
+// NewSetMultimap 构建values不重复的multimap
+func NewSetMultimap[K comparable, V comparable]() *SetMultimap[K, V] {
+	return &SetMultimap[K, V]{m: make(map[K]*multimapValueSet[V])}
+}
+
+// At 取出key对应的values
+func (multimap *SetMultimap[K, V]) At(key K) ([]V, bool) {
+	set, found := multimap.m[key]
+	if !found {
+		return nil, false
+	}
+	return set.values, true
+}
+
+// Contains 判断key对应的values中是否有value
+func (multimap *SetMultimap[K, V]) Contains(key K, value V) bool {
+	set, found := multimap.m[key]
+	if !found {
+		return false
+	}
+	_, found = set.index[value]
+	return found
+}
+
+// Insert 插入一条记录，value已经存在时不插入
+func (multimap *SetMultimap[K, V]) Insert(key K, value V) {
+	set, found := multimap.m[key]
+	if !found {
+		set = &multimapValueSet[V]{index: make(map[V]int)}
+		multimap.m[key] = set
+	}
+	if _, found = set.index[value]; found {
+		return
+	}
+	set.index[value] = len(set.values)
+	set.values = append(set.values, value)
+	multimap.size++
+}
+
+// InsertValues 插入多条记录
+func (multimap *SetMultimap[K, V]) InsertValues(key K, values []V) {
+	for _, v := range values {
+		multimap.Insert(key, v)
+	}
+}
+
+// Remove 移除一条记录，剩余的values复制到新的切片，之前通过At取得的切片不会被修改
+func (multimap *SetMultimap[K, V]) Remove(key K, value V) {
+	set, found := multimap.m[key]
+	if !found {
+		return
+	}
+	idx, found := set.index[value]
+	if !found {
+		return
+	}
+	delete(set.index, value)
+	set.values = slices.Concat(set.values[:idx], set.values[idx+1:])
+	for i := idx; i < len(set.values); i++ {
+		set.index[set.values[i]] = i
+	}
+	multimap.size--
+	if len(set.values) == 0 {
+		delete(multimap.m, key)
+	}
+}
+
+// RemoveAll 删除关于key的所有键值对
+func (multimap *SetMultimap[K, V]) RemoveAll(key K) {
+	set, found := multimap.m[key]
+	if found {
+		multimap.size -= len(set.values)
+		delete(multimap.m, key)
+	}
+}
+
+// Size 获取map当前键值对的数量
+func (multimap *SetMultimap[K, V]) Size() int {
+	return multimap.size
+}
+
+// IsEmpty 判断容器内是否为空
+func (multimap *SetMultimap[K, V]) IsEmpty() bool {
+	return multimap.size == 0
+}
+
+// Count 获取key对应的value数量
+func (multimap *SetMultimap[K, V]) Count(key K) int {
+	set, found := multimap.m[key]
+	if found {
+		return len(set.values)
+	}
+	return 0
+}
+
+// Keys 获取所有的key，顺序不固定
+func (multimap *SetMultimap[K, V]) Keys() []K {
+	keys := make([]K, 0, len(multimap.m))
+	for key := range multimap.m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Values 获取所有的value，key的顺序不固定，同一个key的values按照插入顺序排列
+func (multimap *SetMultimap[K, V]) Values() []V {
+	return multimapValues[K, V](multimap)
+}
+
+// Entries 获取所有的键值对，key的顺序不固定，同一个key的values按照插入顺序排列
+func (multimap *SetMultimap[K, V]) Entries() []MultimapEntry[K, V] {
+	return multimapEntries[K, V](multimap)
+}
+
+// ForEach 遍历所有的键值对，key的顺序不固定，同一个key的values按照插入顺序排列，fn返回false时停止，fn中不能修改这个multimap
+func (multimap *SetMultimap[K, V]) ForEach(fn func(key K, value V) bool) {
+	multimapForEach[K, V](multimap, fn)
+}
+
+// All 返回遍历所有键值对的迭代器，顺序同ForEach，循环中不能修改这个multimap
+func (multimap *SetMultimap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		multimap.ForEach(yield)
+	}
+}
+
+// ToMap 转换成map[K][]V，返回的是副本
+func (multimap *SetMultimap[K, V]) ToMap() map[K][]V {
+	return multimapToMap[K, V](multimap)
+}
+
+// MarshalJSON 序列化成{"key": [values...]}，key的顺序不固定，实现json.Marshaler
+func (multimap *SetMultimap[K, V]) MarshalJSON() ([]byte, error) {
+	return marshalMultimap[K, V](multimap)
+}
+
+// UnmarshalJSON 从{"key": [values...]}反序列化，替换原有的内容，重复的value只保留一条，实现json.Unmarshaler
+func (multimap *SetMultimap[K, V]) UnmarshalJSON(data []byte) error {
+	return unmarshalMultimap(data, func() {
+		multimap.m = make(map[K]*multimapValueSet[V])
+		multimap.size = 0
+	}, multimap.InsertValues)
+}
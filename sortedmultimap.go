@@ -0,0 +1,188 @@
+package lodago
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+// key按照从小到大的顺序排列的multimap，在MultimapOf之外维护一个有序的key切片，
+// 插入新key和删除key时二分查找位置，查询Floor、Ceiling和Range都是O(log n)。
+
+// SortedMultimap key有序的multimap
+type SortedMultimap[K cmp.Ordered, V any] struct {
+	m    *MultimapOf[K, V]
+	keys []K // 从小到大排列
+}
+
+var _ Multimapper[string, int] = (*SortedMultimap[string, int])(nil)
+
+// NewSortedMultimap 构建key有序的multimap，equal为可选的value比较函数，同NewMultimapOf
+func NewSortedMultimap[K cmp.Ordered, V any](equal ...func(a, b V) bool) *SortedMultimap[K, V] {
+	return &SortedMultimap[K, V]{m: NewMultimapOf[K, V](equal...)}
+}
+
+// At 取出key对应的values
+func (multimap *SortedMultimap[K, V]) At(key K) ([]V, bool) {
+	return multimap.m.At(key)
+}
+
+// Insert 插入一条记录
+func (multimap *SortedMultimap[K, V]) Insert(key K, value V) {
+	if multimap.m.Count(key) == 0 {
+		multimap.addKey(key)
+	}
+	multimap.m.Insert(key, value)
+}
+
+// InsertValues 插入多条记录
+func (multimap *SortedMultimap[K, V]) InsertValues(key K, values []V) {
+	for _, v := range values {
+		multimap.Insert(key, v)
+	}
+}
+
+// Remove 移除一条记录
+func (multimap *SortedMultimap[K, V]) Remove(key K, value V) {
+	multimap.m.Remove(key, value)
+	if multimap.m.Count(key) == 0 {
+		multimap.removeKey(key)
+	}
+}
+
+// RemoveAll 删除关于key的所有键值对
+func (multimap *SortedMultimap[K, V]) RemoveAll(key K) {
+	multimap.m.RemoveAll(key)
+	multimap.removeKey(key)
+}
+
+// Size 获取map当前键值对的数量
+func (multimap *SortedMultimap[K, V]) Size() int {
+	return multimap.m.Size()
+}
+
+// IsEmpty 判断容器内是否为空
+func (multimap *SortedMultimap[K, V]) IsEmpty() bool {
+	return multimap.m.IsEmpty()
+}
+
+// Count 获取key对应的value数量
+func (multimap *SortedMultimap[K, V]) Count(key K) int {
+	return multimap.m.Count(key)
+}
+
+// Keys 获取所有的key，从小到大排列
+func (multimap *SortedMultimap[K, V]) Keys() []K {
+	return slices.Clone(multimap.keys)
+}
+
+// First 获取最小的key，为空时返回false
+func (multimap *SortedMultimap[K, V]) First() (K, bool) {
+	if len(multimap.keys) == 0 {
+		var zero K
+		return zero, false
+	}
+	return multimap.keys[0], true
+}
+
+// Last 获取最大的key，为空时返回false
+func (multimap *SortedMultimap[K, V]) Last() (K, bool) {
+	if len(multimap.keys) == 0 {
+		var zero K
+		return zero, false
+	}
+	return multimap.keys[len(multimap.keys)-1], true
+}
+
+// Floor 获取小于等于key的最大的key，不存在时返回false
+func (multimap *SortedMultimap[K, V]) Floor(key K) (K, bool) {
+	idx, found := slices.BinarySearch(multimap.keys, key)
+	if found {
+		return multimap.keys[idx], true
+	}
+	if idx == 0 {
+		var zero K
+		return zero, false
+	}
+	return multimap.keys[idx-1], true
+}
+
+// Ceiling 获取大于等于key的最小的key，不存在时返回false
+func (multimap *SortedMultimap[K, V]) Ceiling(key K) (K, bool) {
+	idx, _ := slices.BinarySearch(multimap.keys, key)
+	if idx == len(multimap.keys) {
+		var zero K
+		return zero, false
+	}
+	return multimap.keys[idx], true
+}
+
+// Range 获取from <= key < to的所有key，从小到大排列
+func (multimap *SortedMultimap[K, V]) Range(from, to K) []K {
+	start, _ := slices.BinarySearch(multimap.keys, from)
+	end, _ := slices.BinarySearch(multimap.keys, to)
+	if start >= end {
+		return nil
+	}
+	return slices.Clone(multimap.keys[start:end])
+}
+
+// addKey 把新的key插入到有序切片中
+func (multimap *SortedMultimap[K, V]) addKey(key K) {
+	idx, found := slices.BinarySearch(multimap.keys, key)
+	if !found {
+		multimap.keys = slices.Insert(multimap.keys, idx, key)
+	}
+}
+
+// removeKey 从有序切片中删除key
+func (multimap *SortedMultimap[K, V]) removeKey(key K) {
+	idx, found := slices.BinarySearch(multimap.keys, key)
+	if found {
+		multimap.keys = slices.Delete(multimap.keys, idx, idx+1)
+	}
+}
+
+// Values 获取所有的value，key从小到大排列
+func (multimap *SortedMultimap[K, V]) Values() []V {
+	return multimapValues[K, V](multimap)
+}
+
+// Entries 获取所有的键值对，key从小到大排列
+func (multimap *SortedMultimap[K, V]) Entries() []MultimapEntry[K, V] {
+	return multimapEntries[K, V](multimap)
+}
+
+// ForEach 遍历所有的键值对，key从小到大排列，fn返回false时停止，fn中不能修改这个multimap
+func (multimap *SortedMultimap[K, V]) ForEach(fn func(key K, value V) bool) {
+	multimapForEach[K, V](multimap, fn)
+}
+
+// All 返回遍历所有键值对的迭代器，顺序同ForEach，循环中不能修改这个multimap
+func (multimap *SortedMultimap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		multimap.ForEach(yield)
+	}
+}
+
+// ToMap 转换成map[K][]V，返回的是副本
+func (multimap *SortedMultimap[K, V]) ToMap() map[K][]V {
+	return multimapToMap[K, V](multimap)
+}
+
+// MarshalJSON 序列化成{"key": [values...]}，key从小到大排列，实现json.Marshaler
+func (multimap *SortedMultimap[K, V]) MarshalJSON() ([]byte, error) {
+	return marshalMultimap[K, V](multimap)
+}
+
+// UnmarshalJSON 从{"key": [values...]}反序列化，替换原有的内容，实现json.Unmarshaler
+func (multimap *SortedMultimap[K, V]) UnmarshalJSON(data []byte) error {
+	return unmarshalMultimap(data, func() {
+		var equal func(a, b V) bool
+		if multimap.m != nil {
+			equal = multimap.m.equal
+		}
+		multimap.m = NewMultimapOf[K, V](equal)
+		multimap.keys = nil
+	}, multimap.InsertValues)
+}
@@ -0,0 +1,134 @@
+package lodago
+
+import (
+	"context"
+	"sync"
+)
+
+// 内存队列满了之后溢出到持久化队列，一旦开始溢出，之后的记录都写入磁盘，直到磁盘中的记录被取完，
+// 保证记录按照插入顺序取出。从磁盘取出的记录和DiskQueue一样，处理完之后需要调用Ack，
+// 没有确认的记录重启之后会再次取出。内存中的记录只有调用Close时才会写入磁盘，进程崩溃时会丢失。
+//
+// 注意：Close时内存中的记录比磁盘中没有取出的记录更早插入，但是只能追加到磁盘的末尾，
+// 所以两边都有记录时关闭并重新打开之后，取出的顺序不再是插入顺序：先取出原来磁盘中的记录，再取出原来内存中的记录。
+
+// SpillMemoryOffset 记录来自内存队列，没有磁盘位置，Ack时忽略
+const SpillMemoryOffset = ^uint64(0)
+
+// SpillQueue 溢出到磁盘的队列
+type SpillQueue[T any] struct {
+	memory   *QueueOf[T]
+	disk     *DiskQueue[T]
+	locker   sync.Mutex // 保证插入时对写入位置的选择和写入是原子的
+	notEmpty qnotifier
+}
+
+// NewSpillQueue 创建溢出队列，memory满了之后写入disk
+func NewSpillQueue[T any](memory *QueueOf[T], disk *DiskQueue[T]) *SpillQueue[T] {
+	return &SpillQueue[T]{memory: memory, disk: disk}
+}
+
+// Put 插入一条记录，内存队列已满或者磁盘中还有没有取出的记录时写入磁盘
+func (q *SpillQueue[T]) Put(value T) error {
+	q.locker.Lock()
+	err := q.put(value)
+	q.locker.Unlock()
+	if err == nil {
+		q.notEmpty.broadcast()
+	}
+	return err
+}
+
+// Get 取出一条记录，先取内存中的记录，内存队列为空时再取磁盘中的记录，返回值、位置和是否成功，没有记录时立即返回false。
+// 处理完之后用返回的位置调用Ack，内存中的记录返回SpillMemoryOffset
+func (q *SpillQueue[T]) Get() (T, uint64, bool, error) {
+	var bo qbackoff
+	for {
+		value, status, _ := q.memory.tryGet()
+		switch status {
+		case qSuccess:
+			return value, SpillMemoryOffset, true, nil
+		case qContended: // 内存中还有记录，只是竞争失败，不能先取出磁盘中更晚插入的记录
+			bo.pause()
+			continue
+		}
+		return q.disk.Get()
+	}
+}
+
+// GetContext 取出一条记录，没有记录时阻塞，直到有新的记录或者ctx结束，队列关闭并且取完时返回ErrQueueClosed。
+// 处理完之后用返回的位置调用Ack
+func (q *SpillQueue[T]) GetContext(ctx context.Context) (T, uint64, error) {
+	var value T
+	var offset uint64
+	var err error
+	blockErr := qblock(ctx, &q.notEmpty, func() int {
+		var ok bool
+		value, offset, ok, err = q.Get()
+		switch {
+		case err == ErrQueueClosed:
+			return qClosed
+		case ok || err != nil:
+			return qSuccess
+		case q.memory.IsClosed():
+			return qClosed
+		}
+		return qNoRoom
+	})
+	if blockErr != nil {
+		return value, 0, blockErr
+	}
+	return value, offset, err
+}
+
+// Ack 确认offset以及之前的所有磁盘记录，同DiskQueue.Ack，offset为SpillMemoryOffset时忽略。
+// 确认是累计的：确认一个较大的offset时，之前取出但是还没有处理完的磁盘记录也会被确认，
+// 多个协程并发处理时，需要等更早的记录都处理完之后再确认，否则崩溃之后这些记录不会再次取出
+func (q *SpillQueue[T]) Ack(offset uint64) error {
+	if offset == SpillMemoryOffset {
+		return nil
+	}
+	return q.disk.Ack(offset)
+}
+
+// Len 剩余的记录数量
+func (q *SpillQueue[T]) Len() uint64 {
+	return q.memory.GetQuantity() + q.disk.Pending()
+}
+
+// Close 关闭队列，内存中剩余的记录追加到磁盘的末尾，重新打开之后可以继续取出，然后关闭磁盘队列。
+// 磁盘中还有没有取出的记录时，内存中更早插入的记录会排在它们之后，重新打开之后不再按照插入顺序取出
+func (q *SpillQueue[T]) Close() error {
+	q.locker.Lock()
+	q.memory.Close()
+	var err error
+	for {
+		value, status, _ := q.memory.tryGet()
+		if status != qSuccess {
+			break
+		}
+		if _, err = q.disk.Put(value); err != nil {
+			break
+		}
+	}
+	q.locker.Unlock()
+	if closeErr := q.disk.Close(); err == nil {
+		err = closeErr
+	}
+	q.notEmpty.broadcast()
+	return err
+}
+
+// put 选择写入位置并写入，调用者需要持有锁
+func (q *SpillQueue[T]) put(value T) error {
+	if q.memory.IsClosed() {
+		return ErrQueueClosed
+	}
+	if q.disk.Pending() == 0 {
+		if status, _ := q.memory.tryPut(value); status == qSuccess {
+			return nil
+		}
+	}
+	_, err := q.disk.Put(value)
+	return err
+}
@@ -0,0 +1,131 @@
+package lodago
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 单生产者单消费者（SPSC）的环形队列，生产者和消费者各自只写自己的位置，不需要CAS。
+// 只能有一个协程插入，一个协程取出，否则结果是未定义的。
+
+// SPSCQueue 元素类型为interface{}的单生产者单消费者队列
+type SPSCQueue = SPSCQueueOf[interface{}]
+
+// SPSCQueueOf 元素类型为T的单生产者单消费者队列
+type SPSCQueueOf[T any] struct {
+	_         [cacheLinePad]byte
+	putPos    uint64 // 生产者写入
+	cachedGet uint64 // 生产者缓存的getPos，只有队列看起来满了才重新读取
+	_         [cacheLinePad - 16]byte
+	getPos    uint64 // 消费者写入
+	cachedPut uint64 // 消费者缓存的putPos，只有队列看起来空了才重新读取
+	_         [cacheLinePad - 16]byte
+	capacity  uint64
+	capMod    uint64
+	cache     []T
+	sleepTime time.Duration
+	_         [cacheLinePad]byte
+}
+
+var _ Queuer[int] = (*SPSCQueueOf[int])(nil)
+
+// NewSPSCQueue 创建一个单生产者单消费者队列，容量向上取整为2的次方
+func NewSPSCQueue(capacity uint64, sleepTime time.Duration) *SPSCQueue {
+	return NewSPSCQueueOf[interface{}](capacity, sleepTime)
+}
+
+// NewSPSCQueueOf 创建一个元素类型为T的单生产者单消费者队列
+func NewSPSCQueueOf[T any](capacity uint64, sleepTime time.Duration) *SPSCQueueOf[T] {
+	q := new(SPSCQueueOf[T])
+	q.capacity = minQuantity(capacity)
+	q.capMod = q.capacity - 1
+	q.sleepTime = sleepTime
+	q.cache = make([]T, q.capacity)
+	return q
+}
+
+// ToString 序列化成字符串
+func (q *SPSCQueueOf[T]) ToString() string {
+	getPos := atomic.LoadUint64(&q.getPos)
+	putPos := atomic.LoadUint64(&q.putPos)
+	return fmt.Sprintf("SPSCQueue{capacity: %v, capMod: %v, putPos: %v, getPos: %v}",
+		q.capacity, q.capMod, putPos, getPos)
+}
+
+// GetCapacity 获取容量
+func (q *SPSCQueueOf[T]) GetCapacity() uint64 {
+	return q.capacity
+}
+
+// GetQuantity 获取当前队列剩余多少条记录
+func (q *SPSCQueueOf[T]) GetQuantity() uint64 {
+	getPos := atomic.LoadUint64(&q.getPos)
+	putPos := atomic.LoadUint64(&q.putPos)
+	if putPos >= getPos {
+		return putPos - getPos
+	}
+	return 0
+}
+
+// Put 向队列插入数据，只能在生产者协程调用，返回是否成功，剩余数量。
+func (q *SPSCQueueOf[T]) Put(value T) (bool, uint64) {
+	n, posCnt := q.Puts([]T{value})
+	return n == 1, posCnt
+}
+
+// Get 从队列中获取记录，只能在消费者协程调用，返回取出的值，是否成功，剩余数量。
+func (q *SPSCQueueOf[T]) Get() (T, bool, uint64) {
+	var values [1]T
+	n, posCnt := q.Gets(values[:])
+	return values[0], n == 1, posCnt
+}
+
+// Puts 向队列插入多条数据，只能在生产者协程调用，返回添加的记录数量，剩余数量。
+func (q *SPSCQueueOf[T]) Puts(values []T) (int, uint64) {
+	putPos := q.putPos // 只有生产者写putPos，不需要原子读取
+	free := q.capacity - (putPos - q.cachedGet)
+	if free < uint64(len(values)) {
+		q.cachedGet = atomic.LoadUint64(&q.getPos)
+		free = q.capacity - (putPos - q.cachedGet)
+	}
+	if free == 0 {
+		time.Sleep(q.sleepTime)
+		return 0, q.capacity
+	}
+	putCnt := uint64(len(values))
+	if putCnt > free {
+		putCnt = free
+	}
+	for v := uint64(0); v < putCnt; v++ {
+		q.cache[(putPos+v)&q.capMod] = values[v]
+	}
+	atomic.StoreUint64(&q.putPos, putPos+putCnt) // 发布写入的数据
+	return int(putCnt), putPos + putCnt - q.cachedGet
+}
+
+// Gets 获取多条记录，只能在消费者协程调用，返回获取的记录数量，剩余数量。
+func (q *SPSCQueueOf[T]) Gets(values []T) (int, uint64) {
+	var zero T
+	getPos := q.getPos // 只有消费者写getPos，不需要原子读取
+	posCnt := q.cachedPut - getPos
+	if posCnt < uint64(len(values)) {
+		q.cachedPut = atomic.LoadUint64(&q.putPos)
+		posCnt = q.cachedPut - getPos
+	}
+	if posCnt == 0 {
+		time.Sleep(q.sleepTime)
+		return 0, 0
+	}
+	getCnt := uint64(len(values))
+	if getCnt > posCnt {
+		getCnt = posCnt
+	}
+	for v := uint64(0); v < getCnt; v++ {
+		cache := &q.cache[(getPos+v)&q.capMod]
+		values[v] = *cache
+		*cache = zero // 释放引用
+	}
+	atomic.StoreUint64(&q.getPos, getPos+getCnt) // 归还位置给生产者
+	return int(getCnt), posCnt - getCnt
+}
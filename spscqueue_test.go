@@ -0,0 +1,11 @@
+package lodago
+
+import (
+	"testing"
+	"time"
+)
+
+// 用go test -race运行
+func TestSPSCQueueStress(t *testing.T) {
+	stressQueuer(t, NewSPSCQueueOf[int](64, time.Microsecond), 1, stressCount()*4)
+}
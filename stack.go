@@ -0,0 +1,106 @@
+package lodago
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// 无锁栈（Treiber stack），栈顶是一个原子指针，插入和取出都通过CAS替换栈顶。
+// ABA问题：每次插入都分配新的节点，取出的节点只要还被某个协程引用就不会被GC回收和复用，
+// 所以CAS比较的指针相同就一定是同一个节点，不需要额外的版本号。
+
+// Stack 元素类型为interface{}的无锁栈
+type Stack = StackOf[interface{}]
+
+// 栈的节点，创建之后只读
+type stackNode[T any] struct {
+	value T
+	next  *stackNode[T]
+}
+
+// StackOf 元素类型为T的无锁栈
+type StackOf[T any] struct {
+	_     [cacheLinePad]byte
+	top   atomic.Pointer[stackNode[T]]
+	_     [cacheLinePad - 8]byte
+	count int64
+	// 指标
+	casRetries uint64 // CAS竞争失败的次数
+}
+
+// NewStack 创建一个无锁栈
+func NewStack() *Stack {
+	return NewStackOf[interface{}]()
+}
+
+// NewStackOf 创建一个元素类型为T的无锁栈
+func NewStackOf[T any]() *StackOf[T] {
+	return new(StackOf[T])
+}
+
+// ToString 序列化成字符串
+func (s *StackOf[T]) ToString() string {
+	return fmt.Sprintf("Stack{quantity: %v, casRetries: %v}", s.GetQuantity(), atomic.LoadUint64(&s.casRetries))
+}
+
+// GetQuantity 获取当前栈中的记录数量
+func (s *StackOf[T]) GetQuantity() uint64 {
+	if count := atomic.LoadInt64(&s.count); count > 0 {
+		return uint64(count)
+	}
+	return 0
+}
+
+// Push 压入一条记录
+func (s *StackOf[T]) Push(value T) {
+	var bo qbackoff
+	node := &stackNode[T]{value: value}
+	for {
+		node.next = s.top.Load()
+		if s.top.CompareAndSwap(node.next, node) {
+			atomic.AddInt64(&s.count, 1)
+			return
+		}
+		atomic.AddUint64(&s.casRetries, 1)
+		bo.pause()
+	}
+}
+
+// Pop 弹出栈顶的记录，栈为空时返回false
+func (s *StackOf[T]) Pop() (T, bool) {
+	var bo qbackoff
+	for {
+		top := s.top.Load()
+		if top == nil {
+			var zero T
+			return zero, false
+		}
+		if s.top.CompareAndSwap(top, top.next) {
+			atomic.AddInt64(&s.count, -1)
+			return top.value, true
+		}
+		atomic.AddUint64(&s.casRetries, 1)
+		bo.pause()
+	}
+}
+
+// Peek 查看栈顶的记录但不弹出，栈为空时返回false
+func (s *StackOf[T]) Peek() (T, bool) {
+	top := s.top.Load()
+	if top == nil {
+		var zero T
+		return zero, false
+	}
+	return top.value, true
+}
+
+// Clear 清空栈，返回清空之前栈中的记录，从栈顶到栈底
+func (s *StackOf[T]) Clear() []T {
+	top := s.top.Swap(nil)
+	var values []T
+	for node := top; node != nil; node = node.next {
+		values = append(values, node.value)
+	}
+	atomic.AddInt64(&s.count, -int64(len(values)))
+	return values
+}
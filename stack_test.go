@@ -0,0 +1,48 @@
+package lodago
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 多个协程并发压入和弹出，检查每个值恰好弹出一次，用go test -race运行
+func TestStackStress(t *testing.T) {
+	const pushers, poppers = 4, 4
+	perPusher := stressCount()
+	total := pushers * perPusher
+	s := NewStackOf[int]()
+	seen := make([]int32, total)
+	var popped int64
+	var wg sync.WaitGroup
+	for p := 0; p < pushers; p++ {
+		wg.Add(1)
+		go func(base int) {
+			defer wg.Done()
+			for i := 0; i < perPusher; i++ {
+				s.Push(base + i)
+			}
+		}(p * perPusher)
+	}
+	for c := 0; c < poppers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&popped) < int64(total) {
+				if v, ok := s.Pop(); ok {
+					atomic.AddInt32(&seen[v], 1)
+					atomic.AddInt64(&popped, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for v, count := range seen {
+		if count != 1 {
+			t.Fatalf("value %d popped %d times", v, count)
+		}
+	}
+	if _, ok := s.Pop(); ok || s.GetQuantity() != 0 {
+		t.Fatalf("stack not empty after draining: %s", s.ToString())
+	}
+}
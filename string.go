@@ -1,7 +1,6 @@
 package lodago
 
 import (
-	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -74,29 +73,14 @@ func SnakeCase(s string, sep ...string) string {
 	return strings.TrimLeft(newStr, sepChar)
 }
 
-// String2Bytes 字符串转换byte切片 零拷贝
+// String2Bytes 字符串转换byte切片 零拷贝，返回的切片不能修改
 func String2Bytes(s string) []byte {
-	stringHeader := (*reflect.StringHeader)(unsafe.Pointer(&s))
-
-	bh := reflect.SliceHeader{
-		Data: stringHeader.Data,
-		Len:  stringHeader.Len,
-		Cap:  stringHeader.Len,
-	}
-
-	return *(*[]byte)(unsafe.Pointer(&bh))
+	return unsafe.Slice(unsafe.StringData(s), len(s))
 }
 
-// Bytes2String byte切片转换字符串 零拷贝
+// Bytes2String byte切片转换字符串 零拷贝，转换之后不能再修改切片
 func Bytes2String(b []byte) string {
-	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-
-	sh := reflect.StringHeader{
-		Data: sliceHeader.Data,
-		Len:  sliceHeader.Len,
-	}
-
-	return *(*string)(unsafe.Pointer(&sh))
+	return unsafe.String(unsafe.SliceData(b), len(b))
 }
 
 // IsNum 判断字符串是不是整数
@@ -0,0 +1,18 @@
+package lodago
+
+import "testing"
+
+func TestStringBytesConversion(t *testing.T) {
+	for _, s := range []string{"", "a", "hello, 世界"} {
+		b := String2Bytes(s)
+		if len(b) != len(s) || string(b) != s {
+			t.Errorf("String2Bytes(%q) = %q", s, b)
+		}
+		if got := Bytes2String([]byte(s)); got != s {
+			t.Errorf("Bytes2String(%q) = %q", s, got)
+		}
+	}
+	if Bytes2String(nil) != "" || len(String2Bytes("")) != 0 {
+		t.Error("empty conversion is not empty")
+	}
+}
@@ -0,0 +1,317 @@
+package lodago
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// 无界队列，由多个固定容量的QueueOf分段串成链表，尾部分段满了之后关闭它并追加新的分段，
+// 头部分段关闭并且取完之后移动到下一个分段，插入只有在队列关闭之后才会失败。
+
+// UnboundedQueue 元素类型为interface{}的无界队列
+type UnboundedQueue = UnboundedQueueOf[interface{}]
+
+// 无界队列的分段
+type uqSegment[T any] struct {
+	queue *QueueOf[T]
+	next  atomic.Pointer[uqSegment[T]]
+}
+
+// UnboundedQueueOf 元素类型为T的无界队列
+type UnboundedQueueOf[T any] struct {
+	head      atomic.Pointer[uqSegment[T]]
+	tail      atomic.Pointer[uqSegment[T]]
+	segSize   uint64
+	sleepTime time.Duration
+	closed    int32
+	segments  int64     // 当前的分段数量
+	notEmpty  qnotifier // 插入数据后通知等待取出的协程
+}
+
+var _ Queuer[int] = (*UnboundedQueueOf[int])(nil)
+
+// NewUnboundedQueue 创建一个无界队列，segmentSize为每个分段的容量，向上取整为2的次方
+func NewUnboundedQueue(segmentSize uint64, sleepTime time.Duration) *UnboundedQueue {
+	return NewUnboundedQueueOf[interface{}](segmentSize, sleepTime)
+}
+
+// NewUnboundedQueueOf 创建一个元素类型为T的无界队列
+func NewUnboundedQueueOf[T any](segmentSize uint64, sleepTime time.Duration) *UnboundedQueueOf[T] {
+	q := &UnboundedQueueOf[T]{segSize: minQuantity(segmentSize), sleepTime: sleepTime}
+	seg := q.newSegment()
+	q.head.Store(seg)
+	q.tail.Store(seg)
+	return q
+}
+
+// ToString 序列化成字符串
+func (q *UnboundedQueueOf[T]) ToString() string {
+	return fmt.Sprintf("UnboundedQueue{segmentSize: %v, segments: %v, quantity: %v, closed: %v}",
+		q.segSize, atomic.LoadInt64(&q.segments), q.GetQuantity(), q.IsClosed())
+}
+
+// GetCapacity 获取容量，无界队列返回math.MaxUint64
+func (q *UnboundedQueueOf[T]) GetCapacity() uint64 {
+	return math.MaxUint64
+}
+
+// GetQuantity 获取当前队列剩余多少条记录
+func (q *UnboundedQueueOf[T]) GetQuantity() uint64 {
+	quantity := uint64(0)
+	for seg := q.head.Load(); seg != nil; seg = seg.next.Load() {
+		quantity += seg.queue.GetQuantity()
+	}
+	return quantity
+}
+
+// Close 关闭队列，之后的插入都会失败，取出可以继续进行直到队列为空，之后取出会返回ErrQueueClosed。
+func (q *UnboundedQueueOf[T]) Close() {
+	if !atomic.CompareAndSwapInt32(&q.closed, 0, 1) {
+		return
+	}
+	// 正在追加的分段在链接之后会检查关闭标记，自己关闭
+	for seg := q.head.Load(); seg != nil; seg = seg.next.Load() {
+		seg.queue.Close()
+	}
+	q.notEmpty.broadcast()
+}
+
+// IsClosed 队列是否已经关闭
+func (q *UnboundedQueueOf[T]) IsClosed() bool {
+	return atomic.LoadInt32(&q.closed) == 1
+}
+
+// Put 向队列插入数据，只有队列关闭时失败，返回是否成功，剩余数量。
+func (q *UnboundedQueueOf[T]) Put(value T) (bool, uint64) {
+	var bo qbackoff
+	for {
+		tail := q.tail.Load()
+		status, _ := tail.queue.tryPut(value)
+		switch status {
+		case qSuccess:
+			q.notEmpty.broadcast()
+			return true, q.GetQuantity()
+		case qContended:
+			bo.pause()
+		default: // 分段已满或者已经关闭
+			if !q.grow(tail) {
+				return false, q.GetQuantity()
+			}
+		}
+	}
+}
+
+// Puts 向队列插入多条数据，只有队列关闭时插入的数量少于values，返回添加的记录数量，剩余数量。
+func (q *UnboundedQueueOf[T]) Puts(values []T) (int, uint64) {
+	var bo qbackoff
+	total := 0
+	for total < len(values) {
+		tail := q.tail.Load()
+		putCnt, status, _ := tail.queue.tryPuts(values[total:])
+		total += putCnt
+		if putCnt > 0 {
+			q.notEmpty.broadcast()
+		}
+		switch status {
+		case qSuccess:
+		case qContended:
+			bo.pause()
+		default:
+			if !q.grow(tail) {
+				return total, q.GetQuantity()
+			}
+		}
+	}
+	return total, q.GetQuantity()
+}
+
+// Get 从队列中获取记录，返回取出的值，是否成功，剩余数量。
+func (q *UnboundedQueueOf[T]) Get() (T, bool, uint64) {
+	value, status := q.tryGet()
+	if status != qSuccess {
+		q.pause(status)
+		return value, false, q.GetQuantity()
+	}
+	return value, true, q.GetQuantity()
+}
+
+// Gets 获取多条记录，返回获取的记录数量，剩余数量。
+func (q *UnboundedQueueOf[T]) Gets(values []T) (int, uint64) {
+	getCnt, status := q.tryGets(values)
+	if status != qSuccess {
+		q.pause(status)
+	}
+	return getCnt, q.GetQuantity()
+}
+
+// PutContext 向队列插入数据，不会因为容量而阻塞，队列关闭时返回ErrQueueClosed
+func (q *UnboundedQueueOf[T]) PutContext(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ok, _ := q.Put(value); !ok {
+		return ErrQueueClosed
+	}
+	return nil
+}
+
+// PutsContext 向队列插入多条数据，不会因为容量而阻塞，队列关闭时返回ErrQueueClosed和已插入的数量
+func (q *UnboundedQueueOf[T]) PutsContext(ctx context.Context, values []T) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if putCnt, _ := q.Puts(values); putCnt < len(values) {
+		return putCnt, ErrQueueClosed
+	}
+	return len(values), nil
+}
+
+// GetContext 从队列中获取记录，队列为空时阻塞，直到取出成功或者ctx被取消、超时，
+// 队列关闭并且已经取完时返回ErrQueueClosed。
+func (q *UnboundedQueueOf[T]) GetContext(ctx context.Context) (T, error) {
+	var value T
+	err := qblock(ctx, &q.notEmpty, func() int {
+		var status int
+		value, status = q.tryGet()
+		return status
+	})
+	return value, err
+}
+
+// GetsContext 获取多条记录，队列为空时阻塞，直到至少取出一条或者ctx被取消、超时、队列关闭并且已经取完，返回取出的数量。
+func (q *UnboundedQueueOf[T]) GetsContext(ctx context.Context, values []T) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	getCnt := 0
+	err := qblock(ctx, &q.notEmpty, func() int {
+		var status int
+		getCnt, status = q.tryGets(values)
+		return status
+	})
+	return getCnt, err
+}
+
+// Chan 将队列转换成只读通道，队列关闭并且取完之后通道关闭，注意事项和QueueOf.Chan相同
+func (q *UnboundedQueueOf[T]) Chan() <-chan T {
+	ch := make(chan T)
+	go func() {
+		defer close(ch)
+		for {
+			value, err := q.GetContext(context.Background())
+			if err != nil {
+				return
+			}
+			ch <- value
+		}
+	}()
+	return ch
+}
+
+// All 返回一个迭代器，可以用for range阻塞地取出队列的记录，队列关闭并且取完之后结束
+func (q *UnboundedQueueOf[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			value, err := q.GetContext(context.Background())
+			if err != nil || !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Collect 收集队列的指标，实现Collector接口
+func (q *UnboundedQueueOf[T]) Collect() []Metric {
+	return []Metric{
+		{Name: "lodago_queue_quantity", Help: "Number of items in the queue.", Type: GaugeMetric,
+			Samples: []MetricSample{{Value: float64(q.GetQuantity())}}},
+		{Name: "lodago_queue_segments", Help: "Number of segments in the unbounded queue.", Type: GaugeMetric,
+			Samples: []MetricSample{{Value: float64(atomic.LoadInt64(&q.segments))}}},
+	}
+}
+
+// newSegment 创建一个分段
+func (q *UnboundedQueueOf[T]) newSegment() *uqSegment[T] {
+	atomic.AddInt64(&q.segments, 1)
+	return &uqSegment[T]{queue: NewQueueOf[T](q.segSize, q.sleepTime)}
+}
+
+// grow 关闭已满的尾部分段并追加新的分段，队列已经关闭时返回false
+func (q *UnboundedQueueOf[T]) grow(tail *uqSegment[T]) bool {
+	if q.IsClosed() {
+		return false
+	}
+	tail.queue.Close() // 封住已满的分段，已经预定位置的插入不受影响
+	next := tail.next.Load()
+	if next == nil {
+		seg := q.newSegment()
+		if tail.next.CompareAndSwap(nil, seg) {
+			next = seg
+			if q.IsClosed() { // 链接之前Close已经遍历过分段
+				seg.queue.Close()
+			}
+		} else {
+			atomic.AddInt64(&q.segments, -1)
+			next = tail.next.Load()
+		}
+	}
+	q.tail.CompareAndSwap(tail, next)
+	return true
+}
+
+// advance 头部分段关闭并且取完之后移动到下一个分段，没有下一个分段时返回false
+func (q *UnboundedQueueOf[T]) advance(head *uqSegment[T]) bool {
+	next := head.next.Load()
+	if next == nil {
+		return false
+	}
+	if q.head.CompareAndSwap(head, next) {
+		atomic.AddInt64(&q.segments, -1)
+	}
+	return true
+}
+
+// tryGet 尝试取出一条记录，不等待
+func (q *UnboundedQueueOf[T]) tryGet() (T, int) {
+	for {
+		head := q.head.Load()
+		value, status, _ := head.queue.tryGet()
+		if status != qClosed || !q.advance(head) {
+			return value, q.status(status)
+		}
+	}
+}
+
+// tryGets 尝试获取多条记录，不等待
+func (q *UnboundedQueueOf[T]) tryGets(values []T) (int, int) {
+	for {
+		head := q.head.Load()
+		getCnt, status, _ := head.queue.tryGets(values)
+		if status != qClosed || !q.advance(head) {
+			return getCnt, q.status(status)
+		}
+	}
+}
+
+// status 分段关闭但是还没有追加下一个分段时当作队列为空，只有整个队列关闭才返回qClosed
+func (q *UnboundedQueueOf[T]) status(status int) int {
+	if status == qClosed && !q.IsClosed() {
+		return qNoRoom
+	}
+	return status
+}
+
+// pause 失败之后等待一段时间
+func (q *UnboundedQueueOf[T]) pause(status int) {
+	switch status {
+	case qNoRoom:
+		time.Sleep(q.sleepTime)
+	case qContended:
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,113 @@
+package lodago
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// uqSegments 从指标中读取当前的分段数量
+func uqSegments[T any](t *testing.T, q *UnboundedQueueOf[T]) float64 {
+	t.Helper()
+	v, ok := findSample(q.Collect(), "lodago_queue_segments", nil)
+	if !ok {
+		t.Fatal("no lodago_queue_segments metric")
+	}
+	return v
+}
+
+// 分段满了之后追加新的分段，头部分段取完之后释放
+func TestUnboundedQueueSegments(t *testing.T) {
+	q := NewUnboundedQueueOf[int](4, time.Microsecond)
+	for i := 0; i < 10; i++ {
+		if ok, _ := q.Put(i); !ok {
+			t.Fatalf("Put(%d) failed", i)
+		}
+	}
+	if n := uqSegments(t, q); n != 3 || q.GetQuantity() != 10 {
+		t.Fatalf("segments %v, quantity %d, want 3 and 10", n, q.GetQuantity())
+	}
+	for i := 0; i < 5; i++ {
+		if v, ok, _ := q.Get(); !ok || v != i {
+			t.Fatalf("Get = %d, %v, want %d", v, ok, i)
+		}
+	}
+	if n := uqSegments(t, q); n != 2 {
+		t.Fatalf("segments after draining the first = %v, want 2", n)
+	}
+	// 批量插入跨越多个分段
+	values := make([]int, 9)
+	for i := range values {
+		values[i] = 10 + i
+	}
+	if n, _ := q.Puts(values); n != len(values) {
+		t.Fatalf("Puts = %d, want %d", n, len(values))
+	}
+	buf := make([]int, 32)
+	var got []int
+	for {
+		n, _ := q.Gets(buf)
+		if n == 0 {
+			break
+		}
+		got = append(got, buf[:n]...)
+	}
+	want := []int{5, 6, 7, 8, 9}
+	want = append(want, values...)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("values = %v, want %v", got, want)
+	}
+	if n := uqSegments(t, q); n != 1 || q.GetQuantity() != 0 {
+		t.Fatalf("segments %v, quantity %d after draining, want 1 and 0", n, q.GetQuantity())
+	}
+}
+
+func TestUnboundedQueueClose(t *testing.T) {
+	q := NewUnboundedQueueOf[int](2, time.Microsecond)
+	q.Puts([]int{1, 2, 3, 4, 5})
+	q.Close()
+	q.Close()
+	if !q.IsClosed() {
+		t.Fatal("IsClosed = false after Close")
+	}
+	if ok, _ := q.Put(6); ok {
+		t.Fatal("Put succeeded after Close")
+	}
+	if n, err := q.PutsContext(context.Background(), []int{6, 7}); n != 0 || err != ErrQueueClosed {
+		t.Fatalf("PutsContext = %d, %v, want ErrQueueClosed", n, err)
+	}
+	// 关闭之前插入的记录在所有分段中都可以取出
+	var got []int
+	for v := range q.All() {
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5}) {
+		t.Fatalf("values after Close = %v", got)
+	}
+	if _, err := q.GetContext(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("GetContext = %v, want ErrQueueClosed", err)
+	}
+
+	// 阻塞的取出被Close唤醒
+	q = NewUnboundedQueueOf[int](2, time.Microsecond)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := q.GetContext(context.Background())
+		errs <- err
+	}()
+	waitForWaiters(t, &q.notEmpty)
+	q.Close()
+	select {
+	case err := <-errs:
+		if err != ErrQueueClosed {
+			t.Fatalf("GetContext = %v, want ErrQueueClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetContext was not woken by Close")
+	}
+}
+
+func TestUnboundedQueueStress(t *testing.T) {
+	stressQueuer(t, NewUnboundedQueueOf[int](64, time.Microsecond), 4, stressCount())
+}
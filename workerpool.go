@@ -0,0 +1,166 @@
+package lodago
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 消费QueueOf的协程池，每个协程批量取出记录交给处理函数，协程数量可以动态调整，
+// Stop关闭队列并等待剩余的记录处理完。
+
+// WorkerPoolOptions 协程池的选项
+type WorkerPoolOptions[T any] struct {
+	Workers   int                        // 协程数量，默认1
+	BatchSize int                        // 每批最多的记录数量，默认1
+	MaxWait   time.Duration              // 取到第一条记录之后最多等待多久凑满一批，默认0即不等待
+	OnError   func(batch []T, err error) // 处理函数返回错误或者panic时调用
+}
+
+// WorkerPool 消费队列的协程池
+type WorkerPool[T any] struct {
+	queue   *QueueOf[T]
+	handler func([]T) error
+	options WorkerPoolOptions[T]
+	cancels []context.CancelFunc // 每个协程的取消函数，用于减少协程
+	stopped bool
+	locker  sync.Mutex
+	wg      sync.WaitGroup
+	// 指标
+	batches   uint64
+	processed uint64
+	failed    uint64
+	panics    uint64
+}
+
+// NewWorkerPool 创建协程池，handler处理一批记录，options为可选的选项，调用Start之后开始消费
+func NewWorkerPool[T any](queue *QueueOf[T], handler func([]T) error, options ...WorkerPoolOptions[T]) *WorkerPool[T] {
+	p := &WorkerPool[T]{queue: queue, handler: handler}
+	if len(options) > 0 {
+		p.options = options[0]
+	}
+	if p.options.Workers <= 0 {
+		p.options.Workers = 1
+	}
+	if p.options.BatchSize <= 0 {
+		p.options.BatchSize = 1
+	}
+	return p
+}
+
+// Start 启动协程，重复调用没有影响
+func (p *WorkerPool[T]) Start() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.stopped || len(p.cancels) > 0 {
+		return
+	}
+	p.resize(p.options.Workers)
+}
+
+// Resize 调整协程数量，减少的协程处理完当前的一批记录之后退出
+func (p *WorkerPool[T]) Resize(workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	p.options.Workers = workers
+	if p.stopped || len(p.cancels) == 0 { // 已经停止或者还没有启动
+		return
+	}
+	p.resize(workers)
+}
+
+// Workers 当前的协程数量
+func (p *WorkerPool[T]) Workers() int {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return len(p.cancels)
+}
+
+// Stop 关闭队列，等待所有协程处理完队列中剩余的记录之后返回，重复调用没有影响
+func (p *WorkerPool[T]) Stop() {
+	p.locker.Lock()
+	p.stopped = true
+	p.locker.Unlock()
+	p.queue.Close()
+	p.wg.Wait()
+	p.locker.Lock()
+	p.cancels = nil
+	p.locker.Unlock()
+}
+
+// Collect 收集协程池的指标，实现Collector接口，多个协程池可以通过WithLabels区分
+func (p *WorkerPool[T]) Collect() []Metric {
+	return []Metric{
+		{Name: "lodago_pool_workers", Help: "Number of workers in the pool.", Type: GaugeMetric,
+			Samples: []MetricSample{{Value: float64(p.Workers())}}},
+		{Name: "lodago_pool_batches_total", Help: "Number of batches passed to the handler.", Type: CounterMetric,
+			Samples: []MetricSample{{Value: float64(atomic.LoadUint64(&p.batches))}}},
+		{Name: "lodago_pool_items_total", Help: "Number of items handled, by result.", Type: CounterMetric,
+			Samples: []MetricSample{
+				{Labels: map[string]string{"status": "success"}, Value: float64(atomic.LoadUint64(&p.processed))},
+				{Labels: map[string]string{"status": "failure"}, Value: float64(atomic.LoadUint64(&p.failed))},
+			}},
+		{Name: "lodago_pool_panics_total", Help: "Number of panics recovered from the handler.", Type: CounterMetric,
+			Samples: []MetricSample{{Value: float64(atomic.LoadUint64(&p.panics))}}},
+	}
+}
+
+// resize 增加或者减少协程，调用者需要持有锁
+func (p *WorkerPool[T]) resize(workers int) {
+	for len(p.cancels) < workers {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancels = append(p.cancels, cancel)
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+	for len(p.cancels) > workers {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
+
+// work 协程的主循环，队列关闭并且取完或者协程被取消时退出
+func (p *WorkerPool[T]) work(ctx context.Context) {
+	defer p.wg.Done()
+	batch := make([]T, p.options.BatchSize)
+	for {
+		// GetBatch在队列中有记录时不检查ctx，被Resize减少的协程需要在取下一批之前退出
+		if ctx.Err() != nil {
+			return
+		}
+		n, err := p.queue.GetBatch(ctx, batch, p.options.MaxWait)
+		if err != nil {
+			return
+		}
+		p.handle(batch[:n])
+		clear(batch[:n]) // 释放引用
+	}
+}
+
+// handle 调用处理函数，panic视为失败
+func (p *WorkerPool[T]) handle(batch []T) {
+	atomic.AddUint64(&p.batches, 1)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				atomic.AddUint64(&p.panics, 1)
+				err = fmt.Errorf("Worker panic: %v", r)
+			}
+		}()
+		return p.handler(batch)
+	}()
+	if err == nil {
+		atomic.AddUint64(&p.processed, uint64(len(batch)))
+		return
+	}
+	atomic.AddUint64(&p.failed, uint64(len(batch)))
+	if p.options.OnError != nil {
+		p.options.OnError(batch, err)
+	}
+}
@@ -0,0 +1,156 @@
+package lodago
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor 等待cond成立，超时时测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 队列中还有积压时减少协程，多余的协程处理完当前的一批之后退出，不再取出新的记录
+func TestWorkerPoolResizeDown(t *testing.T) {
+	const total = 200
+	q := NewQueueOf[int](256, time.Microsecond)
+	for i := 0; i < total; i++ {
+		q.Put(i)
+	}
+	var started, running, initialDone, maxAfter int32
+	gate := make(chan struct{})
+	pool := NewWorkerPool(q, func([]int) error {
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if atomic.AddInt32(&started, 1) <= 4 { // 前4批阻塞，直到4个协程都在处理
+			<-gate
+			atomic.AddInt32(&initialDone, 1)
+			return nil
+		}
+		if atomic.LoadInt32(&initialDone) == 4 && cur > atomic.LoadInt32(&maxAfter) {
+			atomic.StoreInt32(&maxAfter, cur)
+		}
+		time.Sleep(100 * time.Microsecond)
+		return nil
+	}, WorkerPoolOptions[int]{Workers: 4})
+	pool.Start()
+	waitFor(t, "4 running handlers", func() bool { return atomic.LoadInt32(&running) == 4 })
+	pool.Resize(1)
+	if pool.Workers() != 1 {
+		t.Fatalf("Workers = %d, want 1", pool.Workers())
+	}
+	close(gate)
+	pool.Stop()
+	if started != total {
+		t.Fatalf("handled %d batches, want %d", started, total)
+	}
+	if maxAfter > 1 {
+		t.Fatalf("%d handlers ran at once after Resize(1)", maxAfter)
+	}
+}
+
+func TestWorkerPoolResizeUp(t *testing.T) {
+	q := NewQueueOf[int](64, time.Microsecond)
+	var running int32
+	gate := make(chan struct{})
+	pool := NewWorkerPool(q, func([]int) error {
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		<-gate
+		return nil
+	})
+	pool.Start()
+	for i := 0; i < 10; i++ {
+		q.Put(i)
+	}
+	waitFor(t, "1 running handler", func() bool { return atomic.LoadInt32(&running) == 1 })
+	pool.Resize(3)
+	if pool.Workers() != 3 {
+		t.Fatalf("Workers = %d, want 3", pool.Workers())
+	}
+	waitFor(t, "3 running handlers", func() bool { return atomic.LoadInt32(&running) == 3 })
+	close(gate)
+	pool.Stop()
+}
+
+// Stop关闭队列，等待剩余的记录都处理完之后返回
+func TestWorkerPoolStopDrainsQueue(t *testing.T) {
+	q := NewQueueOf[int](128, time.Microsecond)
+	for i := 0; i < 100; i++ {
+		q.Put(i)
+	}
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	pool := NewWorkerPool(q, func(batch []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, v := range batch {
+			seen[v] = true
+		}
+		return nil
+	}, WorkerPoolOptions[int]{Workers: 2, BatchSize: 10, MaxWait: time.Millisecond})
+	pool.Start()
+	pool.Stop()
+	pool.Stop() // 重复调用没有影响
+	if len(seen) != 100 || q.GetQuantity() != 0 {
+		t.Fatalf("handled %d items, %d left in the queue", len(seen), q.GetQuantity())
+	}
+	if ok, _ := q.Put(100); ok {
+		t.Fatal("Put succeeded after Stop")
+	}
+	if v, _ := findSample(pool.Collect(), "lodago_pool_items_total", map[string]string{"status": "success"}); v != 100 {
+		t.Fatalf("success items = %v, want 100", v)
+	}
+}
+
+// 处理函数panic和返回错误一样视为失败，协程继续处理之后的记录
+func TestWorkerPoolPanicIsFailure(t *testing.T) {
+	q := NewQueueOf[int](16, time.Microsecond)
+	for i := 0; i < 6; i++ {
+		q.Put(i)
+	}
+	var mu sync.Mutex
+	var failed []int
+	var errs []error
+	pool := NewWorkerPool(q, func(batch []int) error {
+		switch batch[0] {
+		case 2:
+			panic("boom")
+		case 4:
+			return errors.New("handler failed")
+		}
+		return nil
+	}, WorkerPoolOptions[int]{OnError: func(batch []int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, batch...)
+		errs = append(errs, err)
+	}})
+	pool.Start()
+	pool.Stop()
+	if len(failed) != 2 || failed[0] != 2 || failed[1] != 4 {
+		t.Fatalf("failed batches = %v, want [2 4]", failed)
+	}
+	if errs[0] == nil || errs[0].Error() != "Worker panic: boom" {
+		t.Fatalf("panic error = %v", errs[0])
+	}
+	metrics := pool.Collect()
+	for name, want := range map[string]float64{"success": 4, "failure": 2} {
+		if v, _ := findSample(metrics, "lodago_pool_items_total", map[string]string{"status": name}); v != want {
+			t.Errorf("%s items = %v, want %v", name, v, want)
+		}
+	}
+	if v, _ := findSample(metrics, "lodago_pool_panics_total", nil); v != 1 {
+		t.Errorf("panics = %v, want 1", v)
+	}
+}
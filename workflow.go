@@ -0,0 +1,300 @@
+package lodago
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 在Crontab之上实现的有向无环图（DAG）工作流，步骤之间可以声明依赖关系，
+// 按照拓扑顺序执行，没有依赖关系的步骤并行执行，上游步骤失败时下游步骤不再执行。
+
+// StepStatus 工作流步骤状态
+type StepStatus int32
+
+// 工作流步骤的执行状态
+const (
+	StepPending   StepStatus = 0 // 等待执行
+	StepRunning   StepStatus = 1 // 正在执行
+	StepSucceeded StepStatus = 2 // 执行成功
+	StepFailed    StepStatus = 3 // 执行失败
+	StepSkipped   StepStatus = 4 // 上游步骤失败，跳过执行
+)
+
+// String 状态名称
+func (s StepStatus) String() string {
+	switch s {
+	case StepPending:
+		return "pending"
+	case StepRunning:
+		return "running"
+	case StepSucceeded:
+		return "succeeded"
+	case StepFailed:
+		return "failed"
+	case StepSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrWorkflowRunning 工作流正在执行，不能重复执行
+var ErrWorkflowRunning = errors.New("Workflow is running")
+
+// StepFunc 工作流步骤函数，返回错误代表步骤失败
+type StepFunc func() error
+
+// StepResult 步骤的执行结果
+type StepResult struct {
+	Name      string     `json:"name"`
+	Status    StepStatus `json:"status"`
+	Err       error      `json:"-"`
+	StartTime time.Time  `json:"startTime"`
+	EndTime   time.Time  `json:"endTime"`
+}
+
+// 工作流步骤
+type workflowStep struct {
+	name string
+	deps []string
+	fn   StepFunc
+}
+
+// Workflow 工作流
+type Workflow struct {
+	name    string
+	steps   map[string]*workflowStep
+	order   []string // 步骤的添加顺序，用于稳定输出
+	results map[string]*StepResult
+	running int32
+	locker  sync.RWMutex
+}
+
+// NewWorkflow 创建工作流
+func NewWorkflow(name string) *Workflow {
+	return &Workflow{
+		name:    name,
+		steps:   make(map[string]*workflowStep),
+		results: make(map[string]*StepResult),
+	}
+}
+
+// Name 工作流名称
+func (w *Workflow) Name() string {
+	return w.name
+}
+
+// AddStep 添加步骤，deps为依赖的步骤名称，依赖的步骤可以稍后添加
+func (w *Workflow) AddStep(name string, fn StepFunc, deps ...string) error {
+	if name == "" || fn == nil {
+		return errors.New("Step name or function is empty")
+	}
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if _, found := w.steps[name]; found {
+		return fmt.Errorf("Step %s already exists", name)
+	}
+	w.steps[name] = &workflowStep{name, append([]string(nil), deps...), fn}
+	w.order = append(w.order, name)
+	return nil
+}
+
+// Validate 检查依赖的步骤是否存在，以及是否存在环
+func (w *Workflow) Validate() error {
+	w.locker.RLock()
+	defer w.locker.RUnlock()
+	_, err := w.topoSort()
+	return err
+}
+
+// Run 执行一次工作流，返回第一个失败步骤的错误
+func (w *Workflow) Run() error {
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return ErrWorkflowRunning
+	}
+	defer atomic.StoreInt32(&w.running, 0)
+
+	w.locker.Lock()
+	order, err := w.topoSort()
+	if err != nil {
+		w.locker.Unlock()
+		return err
+	}
+	steps := make(map[string]*workflowStep, len(w.steps))
+	for name, step := range w.steps {
+		steps[name] = step
+	}
+	w.results = make(map[string]*StepResult, len(steps))
+	for _, name := range order {
+		w.results[name] = &StepResult{Name: name, Status: StepPending}
+	}
+	w.locker.Unlock()
+
+	// 计算每个步骤未完成的依赖数量，以及每个步骤的下游步骤
+	remain := make(map[string]int, len(steps))
+	dependents := make(map[string][]string, len(steps))
+	for _, name := range order {
+		remain[name] = len(steps[name].deps)
+		for _, dep := range steps[name].deps {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	done := make(chan *StepResult, len(steps))
+	finished := 0
+	var firstErr error
+	for _, name := range order {
+		if remain[name] == 0 {
+			w.launch(steps[name], done)
+		}
+	}
+	for finished < len(steps) {
+		result := <-done
+		finished++
+		w.setResult(result)
+		if result.Status == StepFailed {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("Workflow %s step %s failed: %w", w.name, result.Name, result.Err)
+			}
+			finished += w.skip(result.Name, dependents)
+			continue
+		}
+		for _, next := range dependents[result.Name] {
+			remain[next]--
+			if remain[next] == 0 && w.statusOf(next) == StepPending {
+				w.launch(steps[next], done)
+			}
+		}
+	}
+	return firstErr
+}
+
+// Status 获取最近一次执行中每个步骤的状态，按照拓扑顺序排列
+func (w *Workflow) Status() []StepResult {
+	w.locker.RLock()
+	defer w.locker.RUnlock()
+	order, err := w.topoSort()
+	if err != nil {
+		order = w.order
+	}
+	results := make([]StepResult, 0, len(order))
+	for _, name := range order {
+		if result, found := w.results[name]; found {
+			results = append(results, *result)
+		} else {
+			results = append(results, StepResult{Name: name, Status: StepPending})
+		}
+	}
+	return results
+}
+
+// IsRunning 工作流是否正在执行
+func (w *Workflow) IsRunning() bool {
+	return atomic.LoadInt32(&w.running) == 1
+}
+
+// launch 在新的协程中执行步骤，步骤内的panic视为失败
+func (w *Workflow) launch(step *workflowStep, done chan<- *StepResult) {
+	start := time.Now()
+	w.setResult(&StepResult{Name: step.name, Status: StepRunning, StartTime: start})
+	go func() {
+		result := &StepResult{Name: step.name, StartTime: start}
+		defer func() {
+			if r := recover(); r != nil {
+				result.Err = fmt.Errorf("Step panic: %v", r)
+			}
+			result.EndTime = time.Now()
+			if result.Err != nil {
+				result.Status = StepFailed
+			} else {
+				result.Status = StepSucceeded
+			}
+			done <- result
+		}()
+		result.Err = step.fn()
+	}()
+}
+
+// skip 将失败步骤的所有下游步骤标记为跳过，返回被跳过的数量
+func (w *Workflow) skip(name string, dependents map[string][]string) int {
+	count := 0
+	for _, next := range dependents[name] {
+		if w.statusOf(next) != StepPending {
+			continue
+		}
+		w.setResult(&StepResult{Name: next, Status: StepSkipped})
+		count++
+		count += w.skip(next, dependents)
+	}
+	return count
+}
+
+// 设置步骤结果
+func (w *Workflow) setResult(result *StepResult) {
+	w.locker.Lock()
+	w.results[result.Name] = result
+	w.locker.Unlock()
+}
+
+// 获取步骤状态
+func (w *Workflow) statusOf(name string) StepStatus {
+	w.locker.RLock()
+	defer w.locker.RUnlock()
+	if result, found := w.results[name]; found {
+		return result.Status
+	}
+	return StepPending
+}
+
+// topoSort 按照添加顺序进行拓扑排序（Kahn算法），调用者需要持有锁
+func (w *Workflow) topoSort() ([]string, error) {
+	indegree := make(map[string]int, len(w.steps))
+	dependents := make(map[string][]string, len(w.steps))
+	for _, name := range w.order {
+		step := w.steps[name]
+		for _, dep := range step.deps {
+			if _, found := w.steps[dep]; !found {
+				return nil, fmt.Errorf("Step %s depends on unknown step %s", name, dep)
+			}
+			dependents[dep] = append(dependents[dep], name)
+		}
+		indegree[name] = len(step.deps)
+	}
+	order := make([]string, 0, len(w.steps))
+	for _, name := range w.order {
+		if indegree[name] == 0 {
+			order = append(order, name)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for _, next := range dependents[order[i]] {
+			indegree[next]--
+			if indegree[next] == 0 {
+				order = append(order, next)
+			}
+		}
+	}
+	if len(order) != len(w.steps) {
+		return nil, fmt.Errorf("Workflow %s has a dependency cycle", w.name)
+	}
+	return order, nil
+}
+
+// AddWorkflow 按照cronTime定时执行工作流，上一次执行未结束时本次触发会被忽略并记为跳过，
+// 工作流失败时记为任务失败
+func (c *Crontab) AddWorkflow(cronTime *CronTime, w *Workflow) (cron.EntryID, error) {
+	if err := w.Validate(); err != nil {
+		return 0, err
+	}
+	return c.addJob(cronTime, func() error {
+		if err := w.Run(); err != ErrWorkflowRunning {
+			return err
+		}
+		return errJobSkipped
+	})
+}
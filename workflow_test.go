@@ -0,0 +1,248 @@
+package lodago
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录步骤执行顺序的工作流
+type workflowRecorder struct {
+	names  []string
+	locker sync.Mutex
+}
+
+func (r *workflowRecorder) step(name string, err error) StepFunc {
+	return func() error {
+		r.locker.Lock()
+		r.names = append(r.names, name)
+		r.locker.Unlock()
+		return err
+	}
+}
+
+func (r *workflowRecorder) ran() []string {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func workflowStatus(w *Workflow) map[string]StepStatus {
+	status := make(map[string]StepStatus)
+	for _, result := range w.Status() {
+		status[result.Name] = result.Status
+	}
+	return status
+}
+
+func TestWorkflowTopologicalOrder(t *testing.T) {
+	w := NewWorkflow("deploy")
+	r := &workflowRecorder{}
+	// 依赖的步骤可以稍后添加
+	for _, step := range []struct {
+		name string
+		deps []string
+	}{
+		{"release", []string{"package"}},
+		{"package", []string{"build", "test"}},
+		{"test", []string{"build"}},
+		{"build", nil},
+	} {
+		if err := w.AddStep(step.name, r.step(step.name, nil), step.deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"build", "test", "package", "release"}
+	if got := r.ran(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %v, want %v", got, want)
+	}
+	var names []string
+	for _, result := range w.Status() {
+		names = append(names, result.Name)
+		if result.Status != StepSucceeded || result.EndTime.Before(result.StartTime) {
+			t.Errorf("%s = %s, start %v, end %v", result.Name, result.Status, result.StartTime, result.EndTime)
+		}
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("Status order = %v, want %v", names, want)
+	}
+}
+
+func TestWorkflowIndependentStepsRunInParallel(t *testing.T) {
+	w := NewWorkflow("parallel")
+	r := &workflowRecorder{}
+	aStarted, bStarted := make(chan struct{}), make(chan struct{})
+	// 两个步骤都要等到对方开始之后才能结束，串行执行时会超时
+	wait := func(self, other chan struct{}) StepFunc {
+		return func() error {
+			close(self)
+			select {
+			case <-other:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("steps are not running in parallel")
+			}
+		}
+	}
+	if err := w.AddStep("a", wait(aStarted, bStarted)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddStep("b", wait(bStarted, aStarted)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddStep("join", r.step("join", nil), "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.ran(); !reflect.DeepEqual(got, []string{"join"}) {
+		t.Fatalf("ran %v", got)
+	}
+}
+
+func TestWorkflowFailureSkipsDownstream(t *testing.T) {
+	errBuild := errors.New("build failed")
+	w := NewWorkflow("ci")
+	r := &workflowRecorder{}
+	steps := []struct {
+		name string
+		fn   StepFunc
+		deps []string
+	}{
+		{"build", r.step("build", errBuild), nil},
+		{"test", r.step("test", nil), []string{"build"}},
+		{"release", r.step("release", nil), []string{"test"}},
+		{"lint", r.step("lint", nil), nil},
+		{"report", func() error { panic("report failed") }, []string{"lint"}},
+		{"notify", r.step("notify", nil), []string{"report"}},
+	}
+	for _, step := range steps {
+		if err := w.AddStep(step.name, step.fn, step.deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Run()
+	if err == nil {
+		t.Fatal("Run succeeded")
+	}
+	if !errors.Is(err, errBuild) && !strings.Contains(err.Error(), "Step panic") {
+		t.Fatalf("Run = %v", err)
+	}
+	want := map[string]StepStatus{
+		"build":   StepFailed,
+		"test":    StepSkipped,
+		"release": StepSkipped,
+		"lint":    StepSucceeded,
+		"report":  StepFailed, // panic视为失败
+		"notify":  StepSkipped,
+	}
+	if got := workflowStatus(w); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Status = %v, want %v", got, want)
+	}
+	ran := r.ran()
+	if len(ran) != 2 {
+		t.Fatalf("ran %v, want only build and lint", ran)
+	}
+	if w.IsRunning() {
+		t.Fatal("workflow still running after Run returned")
+	}
+}
+
+func TestWorkflowInvalidDependencies(t *testing.T) {
+	noop := func() error { return nil }
+	tests := []struct {
+		name  string
+		steps map[string][]string
+		want  string
+	}{
+		{"unknown step", map[string][]string{"a": {"missing"}}, "depends on unknown step missing"},
+		{"cycle", map[string][]string{"a": {"c"}, "b": {"a"}, "c": {"b"}, "d": nil}, "has a dependency cycle"},
+		{"self dependency", map[string][]string{"a": {"a"}}, "has a dependency cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkflow(tt.name)
+			for name, deps := range tt.steps {
+				if err := w.AddStep(name, noop, deps...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate = %v, want %q", err, tt.want)
+			}
+			c := NewCrontab()
+			if _, err := c.AddWorkflow(&CronTime{Type: Daily, Hour: "1", Minute: "0"}, w); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("AddWorkflow = %v, want %q", err, tt.want)
+			}
+			if entries := c.cron.Entries(); len(entries) != 0 {
+				t.Fatalf("invalid workflow was scheduled: %v", entries)
+			}
+			if err := w.Run(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Run = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	w := NewWorkflow("duplicate")
+	if err := w.AddStep("a", noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddStep("a", noop); err == nil {
+		t.Fatal("duplicate step was accepted")
+	}
+	if err := w.AddStep("b", nil); err == nil {
+		t.Fatal("step without function was accepted")
+	}
+}
+
+func TestWorkflowOverlappingRunIsSkipped(t *testing.T) {
+	c := NewCrontab()
+	w := NewWorkflow("wf")
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	if err := w.AddStep("block", func() error {
+		once.Do(func() { close(started) })
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cronTime := &CronTime{Type: Daily, Hour: "1", Minute: "0"}
+	id, err := c.AddWorkflow(cronTime, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- w.Run() }()
+	<-started
+	if !w.IsRunning() {
+		t.Fatal("IsRunning = false while a step is running")
+	}
+	if err := w.Run(); err != ErrWorkflowRunning {
+		t.Fatalf("second Run = %v, want ErrWorkflowRunning", err)
+	}
+	c.cron.Entry(id).Job.Run() // 上一次执行未结束，这次触发被跳过
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	metrics := c.Collect()
+	for status, want := range map[string]float64{"success": 0, "failure": 0, "skipped": 1} {
+		labels := map[string]string{"job": cronTime.Key, "status": status}
+		if v, _ := findSample(metrics, "lodago_crontab_job_runs_total", labels); v != want {
+			t.Errorf("%s = %v, want %v", status, v, want)
+		}
+	}
+	// 上一次执行结束之后可以再次执行
+	c.cron.Entry(id).Job.Run()
+	labels := map[string]string{"job": cronTime.Key, "status": "success"}
+	if v, _ := findSample(c.Collect(), "lodago_crontab_job_runs_total", labels); v != 1 {
+		t.Fatalf("success after overlap = %v, want 1", v)
+	}
+}